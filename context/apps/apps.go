@@ -0,0 +1,91 @@
+// Package apps provides a registry of the configured Facebook applications
+// and their secrets.
+package apps
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/daaku/go.errcode"
+	"github.com/daaku/go.fbapp"
+)
+
+// The Registry of configured applications. The Default application is always
+// included, additional ones are configured as a flag.Value using
+// "id:secret[:namespace]" entries separated by commas.
+type Registry struct {
+	Default fbapp.App
+	Apps    []fbapp.App
+}
+
+// Get the configured application for the ID, or nil if it isn't configured.
+func (r *Registry) Get(id uint64) fbapp.App {
+	for _, app := range r.All() {
+		if app.ID() == id {
+			return app
+		}
+	}
+	return nil
+}
+
+// Get the configured application for the ID to sign or verify with its
+// secret. Unlike the context, which falls back to the Default for display,
+// there is no fallback here since the secret of another app is never right.
+func (r *Registry) Lookup(id uint64) (fbapp.App, error) {
+	if app := r.Get(id); app != nil {
+		return app, nil
+	}
+	return nil, errcode.New(
+		http.StatusBadRequest, "No secret is configured for app %d.", id)
+}
+
+// All configured applications, starting with the Default.
+func (r *Registry) All() []fbapp.App {
+	if r == nil {
+		return nil
+	}
+	all := make([]fbapp.App, 0, len(r.Apps)+1)
+	if r.Default != nil {
+		all = append(all, r.Default)
+	}
+	return append(all, r.Apps...)
+}
+
+// Provides flag.Value.
+func (r *Registry) String() string {
+	var parts []string
+	for _, app := range r.Apps {
+		part := strconv.FormatUint(app.ID(), 10) + ":<secret>"
+		if ns := app.Namespace(); ns != "" {
+			part += ":" + ns
+		}
+		parts = append(parts, part)
+	}
+	return strings.Join(parts, ",")
+}
+
+// Provides flag.Value.
+func (r *Registry) Set(value string) error {
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ":")
+		if len(parts) < 2 || len(parts) > 3 || parts[1] == "" {
+			return fmt.Errorf("invalid app %q, expected id:secret[:namespace]", entry)
+		}
+		id, err := strconv.ParseUint(parts[0], 10, 64)
+		if err != nil {
+			return fmt.Errorf("invalid app id %q: %s", parts[0], err)
+		}
+		var ns string
+		if len(parts) == 3 {
+			ns = parts[2]
+		}
+		r.Apps = append(r.Apps, fbapp.New(id, parts[1], ns))
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package apps
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/daaku/go.fbapp"
+)
+
+func TestSet(t *testing.T) {
+	t.Parallel()
+	r := &Registry{Default: fbapp.New(1, "one", "rell")}
+	if err := r.Set("2:two, 3:three:ns"); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Set("4:four"); err != nil {
+		t.Fatal(err)
+	}
+	if len(r.All()) != 4 {
+		t.Fatalf("Did not find expected 4 apps instead found %d", len(r.All()))
+	}
+	app := r.Get(3)
+	if app == nil || app.Secret() != "three" || app.Namespace() != "ns" {
+		t.Fatalf("Did not find expected app 3 instead found %v", app)
+	}
+	if r.Get(1).Secret() != "one" {
+		t.Fatal("Did not find expected default app.")
+	}
+	if r.Get(5) != nil {
+		t.Fatal("Was not expecting an unconfigured app.")
+	}
+	if s := r.String(); s != "2:<secret>,3:<secret>:ns,4:<secret>" {
+		t.Fatalf("Did not find expected string instead found %s", s)
+	}
+}
+
+func TestSetInvalid(t *testing.T) {
+	t.Parallel()
+	for _, v := range []string{"2", "2:", "x:secret", "2:secret:ns:extra"} {
+		if err := (&Registry{}).Set(v); err == nil {
+			t.Fatalf("Was expecting an error for %q", v)
+		}
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	t.Parallel()
+	var r *Registry
+	if r.Get(1) != nil {
+		t.Fatal("Was not expecting an app from a nil registry.")
+	}
+}
+
+func TestLookup(t *testing.T) {
+	t.Parallel()
+	r := &Registry{
+		Default: fbapp.New(1, "one", "rell"),
+		Apps:    []fbapp.App{fbapp.New(2, "two", "")},
+	}
+	if app, err := r.Lookup(2); err != nil || app.Secret() != "two" {
+		t.Fatalf("Did not find expected app 2 instead found %v %v", app, err)
+	}
+	if app, err := r.Lookup(1); err != nil || app.Secret() != "one" {
+		t.Fatalf("Did not find expected default app instead found %v %v", app, err)
+	}
+	app, err := r.Lookup(3)
+	if err == nil || app != nil {
+		t.Fatalf("Was expecting an error for an unconfigured app, found %v", app)
+	}
+	if !strings.Contains(err.Error(), "3") {
+		t.Fatalf("Did not find expected app ID in %s", err)
+	}
+}
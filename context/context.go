@@ -10,6 +10,7 @@ import (
 	"net/url"
 	"path"
 	"strconv"
+	"strings"
 
 	"github.com/daaku/go.fbapp"
 	"github.com/daaku/go.fburl"
@@ -20,11 +21,16 @@ import (
 	"github.com/gorilla/schema"
 
 	"github.com/daaku/rell/context/appns"
+	"github.com/daaku/rell/context/apps"
 	"github.com/daaku/rell/context/empcheck"
+	"github.com/daaku/rell/requestlog"
 )
 
 const defaultMaxMemory = 32 << 20 // 32 MB
 
+// The cookie holding the saved preferences for the browser.
+const PrefsCookie = "fbrell_prefs"
+
 // The allowed SDK Versions.
 const (
 	Mu  = "mu"
@@ -65,6 +71,8 @@ type Context struct {
 	ViewportMode         string              `schema:"viewport-mode"`
 	IsEmployee           bool                `schema:"-"`
 	Init                 bool                `schema:"init"`
+	RequestID            string              `schema:"-"`
+	prefs                url.Values          `schema:"-"`
 }
 
 // Defaults for the context.
@@ -85,13 +93,26 @@ var defaultContext = &Context{
 
 var (
 	schemaDecoder = schema.NewDecoder()
+
+	// The settings that can be saved as preferences.
+	prefKeys = []string{
+		"locale",
+		"server",
+		"version",
+		"module",
+		"status",
+		"channel",
+		"frictionlessRequests",
+	}
 )
 
 type Parser struct {
 	EmpChecker   *empcheck.Checker
 	AppNSFetcher *appns.Fetcher
 	App          fbapp.App
+	Apps         *apps.Registry
 	Stats        stats.Backend
+	RequestLog   *requestlog.Handler
 }
 
 // Create a default context.
@@ -112,13 +133,16 @@ func (p *Parser) FromRequest(r *http.Request) (*Context, error) {
 		r.Form.Set("appid", id)
 	}
 	context := p.Default()
+	if prefs := readPrefs(r); len(prefs) > 0 {
+		_ = schemaDecoder.Decode(context, prefs)
+		context.prefs = prefs
+	}
 	_ = schemaDecoder.Decode(context, r.URL.Query())
 	_ = schemaDecoder.Decode(context, r.Form)
 	rawSr := r.FormValue("signed_request")
 	if rawSr != "" {
-		context.SignedRequest, err = fbsr.Unmarshal(
-			[]byte(rawSr), p.App.SecretByte())
-		if err == nil {
+		context.SignedRequest = p.signedRequest(context.AppID, rawSr)
+		if context.SignedRequest != nil {
 			if context.SignedRequest.Page != nil {
 				context.ViewMode = PageTab
 			} else {
@@ -128,12 +152,13 @@ func (p *Parser) FromRequest(r *http.Request) (*Context, error) {
 	} else {
 		cookie, _ := r.Cookie(fmt.Sprintf("fbsr_%d", context.AppID))
 		if cookie != nil {
-			context.SignedRequest, err = fbsr.Unmarshal(
-				[]byte(cookie.Value), p.App.SecretByte())
+			context.SignedRequest = p.signedRequest(context.AppID, cookie.Value)
 		}
 	}
 	context.Host = trustforward.Host(r)
 	context.Scheme = trustforward.Scheme(r)
+	context.RequestID = requestlog.ID(r)
+	p.RequestLog.Set(r, "viewMode", context.ViewMode)
 	if context.SignedRequest != nil && context.SignedRequest.UserID != 0 {
 		context.IsEmployee = p.EmpChecker.Check(context.SignedRequest.UserID)
 	}
@@ -144,6 +169,66 @@ func (p *Parser) FromRequest(r *http.Request) (*Context, error) {
 	return context, nil
 }
 
+// Verify the signed request with the secret of the application. There is no
+// signed request if the application isn't configured or it doesn't verify.
+func (p *Parser) signedRequest(id uint64, raw string) *fbsr.SignedRequest {
+	app, err := p.Apps.Lookup(id)
+	if err != nil {
+		return nil
+	}
+	sr, err := fbsr.Unmarshal([]byte(raw), app.SecretByte())
+	if err != nil {
+		return nil
+	}
+	return sr
+}
+
+// Read the saved preferences, ignoring anything that isn't a preference.
+func readPrefs(r *http.Request) url.Values {
+	cookie, err := r.Cookie(PrefsCookie)
+	if err != nil {
+		return nil
+	}
+	saved, err := url.ParseQuery(cookie.Value)
+	if err != nil {
+		return nil
+	}
+	prefs := url.Values{}
+	for _, key := range prefKeys {
+		if value := saved.Get(key); value != "" {
+			prefs.Set(key, value)
+		}
+	}
+	return prefs
+}
+
+// The current value of all the preference settings.
+func (c *Context) prefValues() url.Values {
+	values := url.Values{}
+	values.Set("locale", c.Locale)
+	values.Set("server", c.Env)
+	values.Set("version", c.Version)
+	values.Set("module", c.Module)
+	values.Set("status", strconv.FormatBool(c.Status))
+	values.Set("channel", strconv.FormatBool(c.UseChannel))
+	values.Set("frictionlessRequests", strconv.FormatBool(c.FrictionlessRequests))
+	return values
+}
+
+// The preferences to save, which are the settings that differ from the
+// defaults.
+func (c *Context) Prefs() url.Values {
+	current := c.prefValues()
+	defaults := defaultContext.prefValues()
+	prefs := url.Values{}
+	for _, key := range prefKeys {
+		if current.Get(key) != defaults.Get(key) {
+			prefs.Set(key, current.Get(key))
+		}
+	}
+	return prefs
+}
+
 // Provides a duplicate copy.
 func (c *Context) Copy() *Context {
 	context := *c
@@ -197,6 +282,7 @@ func (c *Context) PageTabURL(name string) string {
 
 // Get the URL for loading this application in a Canvas page on Facebook.
 func (c *Context) CanvasURL(name string) string {
+	name, values := c.pathValues(name)
 	var base = "/" + c.AppNamespace + "/"
 	if name == "" || name == "/" {
 		name = base
@@ -209,7 +295,7 @@ func (c *Context) CanvasURL(name string) string {
 		SubDomain: fburl.DApps,
 		Env:       c.Env,
 		Path:      name,
-		Values:    c.Values(),
+		Values:    values,
 	}
 	return url.String()
 }
@@ -252,17 +338,41 @@ func (c *Context) Values() url.Values {
 	if c.FrictionlessRequests != defaultContext.FrictionlessRequests {
 		values.Set("frictionlessRequests", strconv.FormatBool(c.FrictionlessRequests))
 	}
+	// Defaults that override the saved preferences must be explicit.
+	if len(c.prefs) > 0 {
+		current := c.prefValues()
+		for key, pref := range c.prefs {
+			if _, ok := values[key]; !ok && current.Get(key) != pref[0] {
+				values.Set(key, current.Get(key))
+			}
+		}
+	}
 	return values
 }
 
-// Create a context aware URL for the given path.
+// Create a context aware URL for the given path, which may include a query.
 func (c *Context) URL(path string) *url.URL {
+	path, values := c.pathValues(path)
 	return &url.URL{
 		Path:     path,
-		RawQuery: c.Values().Encode(),
+		RawQuery: values.Encode(),
 	}
 }
 
+// Split the query off a path that may include one, returning the path and
+// the query values with the context values applied on top.
+func (c *Context) pathValues(name string) (string, url.Values) {
+	values := url.Values{}
+	if i := strings.Index(name, "?"); i != -1 {
+		values, _ = url.ParseQuery(name[i+1:])
+		name = name[:i]
+	}
+	for key, value := range c.Values() {
+		values[key] = value
+	}
+	return name, values
+}
+
 // Create a context aware absolute URL for the given path.
 func (c *Context) AbsoluteURL(path string) *url.URL {
 	u := c.URL(path)
@@ -309,5 +419,8 @@ func (c *Context) MarshalJSON() ([]byte, error) {
 	if c.IsEmployee {
 		data["isEmployee"] = true
 	}
+	if c.RequestID != "" {
+		data["requestID"] = c.RequestID
+	}
 	return json.Marshal(data)
 }
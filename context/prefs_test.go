@@ -0,0 +1,124 @@
+package context
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/daaku/go.fbapp"
+
+	"github.com/daaku/rell/context/appns"
+	"github.com/daaku/rell/context/apps"
+)
+
+type nopStats struct{}
+
+func (nopStats) Count(name string, count int)      {}
+func (nopStats) Record(name string, value float64) {}
+
+func newTestParser() *Parser {
+	app := fbapp.New(184484190795, "the-secret", "fbrell")
+	return &Parser{
+		App:          app,
+		Apps:         &apps.Registry{Default: app},
+		AppNSFetcher: &appns.Fetcher{Apps: []fbapp.App{app}},
+		Stats:        nopStats{},
+	}
+}
+
+// A request with the query and the saved preferences, which has an empty
+// multipart body like the form posts the parser accepts.
+func newPrefsRequest(t *testing.T, query, prefs string) *http.Request {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.Close()
+	r, err := http.NewRequest("POST", "http://www.fbrell.com/?"+query, &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	if prefs != "" {
+		r.AddCookie(&http.Cookie{Name: PrefsCookie, Value: prefs})
+	}
+	return r
+}
+
+func TestReadPrefs(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		Cookie   string
+		Expected url.Values
+	}{
+		{"", nil},
+		{"%zz", nil},
+		{"locale=fr_FR&version=mid", url.Values{
+			"locale":  {"fr_FR"},
+			"version": {"mid"},
+		}},
+		{"appid=1&view-mode=canvas&server=beta", url.Values{"server": {"beta"}}},
+	}
+	for _, c := range cases {
+		actual := readPrefs(newPrefsRequest(t, "", c.Cookie))
+		if actual.Encode() != c.Expected.Encode() || (actual == nil) != (c.Expected == nil) {
+			t.Fatalf("Did not find expected prefs %v for %q instead found %v",
+				c.Expected, c.Cookie, actual)
+		}
+	}
+}
+
+func TestPrefsAndValues(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		Name   string
+		Query  string
+		Cookie string
+		Prefs  string
+		Values string
+	}{
+		{
+			Name: "defaults",
+		},
+		{
+			Name:   "saved preference",
+			Cookie: "version=mid",
+			Prefs:  "version=mid",
+			Values: "version=mid",
+		},
+		{
+			Name:   "URL beats preference",
+			Query:  "version=old",
+			Cookie: "locale=fr_FR&version=mid",
+			Prefs:  "locale=fr_FR&version=old",
+			Values: "locale=fr_FR&version=old",
+		},
+		{
+			Name:   "explicit default beats preference",
+			Query:  "version=mu&status=true",
+			Cookie: "version=mid&status=false",
+			Values: "status=true&version=mu",
+		},
+		{
+			Name:   "URL without preference",
+			Query:  "status=false",
+			Prefs:  "status=false",
+			Values: "status=false",
+		},
+	}
+	parser := newTestParser()
+	for _, c := range cases {
+		context, err := parser.FromRequest(newPrefsRequest(t, c.Query, c.Cookie))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if prefs := context.Prefs().Encode(); prefs != c.Prefs {
+			t.Fatalf("Did not find expected prefs %q for %s instead found %q",
+				c.Prefs, c.Name, prefs)
+		}
+		if values := context.Values().Encode(); values != c.Values {
+			t.Fatalf("Did not find expected values %q for %s instead found %q",
+				c.Values, c.Name, values)
+		}
+	}
+}
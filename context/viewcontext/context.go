@@ -3,19 +3,43 @@ package viewcontext
 
 import (
 	"net/http"
+	"net/url"
+	"time"
 
+	"github.com/daaku/go.errcode"
 	"github.com/daaku/go.httpdev"
 	"github.com/daaku/go.static"
+	"github.com/daaku/go.trustforward"
+	"github.com/daaku/go.xsrf"
 
 	"github.com/daaku/rell/context"
 	"github.com/daaku/rell/view"
 )
 
-var version string
+const (
+	PrefsSavePath  = "/prefs/save"
+	PrefsResetPath = "/prefs/reset"
+
+	// The preferences are changed by submitting the example form to the paths
+	// above, which carries this token along with the one for saving examples.
+	XsrfParam  = "-prefs-xsrf-token-"
+	XsrfAction = "/prefs/"
+
+	prefsMaxAge = 365 * 24 * time.Hour
+)
+
+var (
+	version string
+
+	errPostRequired = errcode.New(
+		http.StatusMethodNotAllowed, "Preferences must be changed with a POST.")
+	errTokenMismatch = errcode.New(http.StatusForbidden, "Token mismatch.")
+)
 
 type Handler struct {
 	ContextParser *context.Parser
 	Static        *static.Handler
+	Xsrf          *xsrf.Provider
 }
 
 // Handler for /info/ to see a JSON view of some server context.
@@ -34,3 +58,60 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	}
 	httpdev.Info(info, w, r)
 }
+
+// Save the context settings as the preferences for this browser.
+func (h *Handler) SavePrefs(w http.ResponseWriter, r *http.Request) {
+	c, err := h.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, h.Static, err)
+		return
+	}
+	if err := h.checkPost(w, r); err != nil {
+		view.Error(w, r, h.Static, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     context.PrefsCookie,
+		Value:    c.Prefs().Encode(),
+		Path:     "/",
+		MaxAge:   int(prefsMaxAge / time.Second),
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, returnURL(r, c.ViewURL("/examples/")), 302)
+}
+
+// Reset the preferences for this browser to the defaults.
+func (h *Handler) ResetPrefs(w http.ResponseWriter, r *http.Request) {
+	if err := h.checkPost(w, r); err != nil {
+		view.Error(w, r, h.Static, err)
+		return
+	}
+	http.SetCookie(w, &http.Cookie{
+		Name:     context.PrefsCookie,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+	})
+	http.Redirect(w, r, returnURL(r, "/examples/"), 302)
+}
+
+// Preferences may only be changed by a POST with a valid xsrf token.
+func (h *Handler) checkPost(w http.ResponseWriter, r *http.Request) error {
+	if r.Method != "POST" {
+		return errPostRequired
+	}
+	if !h.Xsrf.Validate(r.FormValue(XsrfParam), w, r, XsrfAction) {
+		return errTokenMismatch
+	}
+	return nil
+}
+
+// Return to the page the request came from if it was on this host, without
+// the query string since that may override the preferences.
+func returnURL(r *http.Request, fallback string) string {
+	ref, err := url.Parse(r.Referer())
+	if err != nil || ref.Host != trustforward.Host(r) || ref.Path == "" {
+		return fallback
+	}
+	return ref.Path
+}
@@ -0,0 +1,104 @@
+package examples
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/daaku/go.errcode"
+)
+
+var errExportAllUnsupported = errcode.New(
+	http.StatusNotImplemented,
+	"Exporting all saved examples requires a store that supports listing"+
+		" (redis, memory or disk). Specify the IDs to export instead.")
+
+// A saved Example in a bundle. Bundles are JSON lines with one entry
+// per line.
+type BundleEntry struct {
+	ID       string    `json:"id"`
+	Content  []byte    `json:"content"`
+	Revision *Revision `json:"revision,omitempty"`
+}
+
+// Export the saved Examples with the given IDs as a bundle. All saved
+// Examples are exported if no IDs are given, which requires a
+// ListByteStore. All the bundled stores support listing. Returns the
+// number of Examples exported.
+func (s *Store) Export(w io.Writer, ids []string) (int, error) {
+	all := len(ids) == 0
+	if all {
+		ls, ok := s.ByteStore.(ListByteStore)
+		if !ok {
+			return 0, errExportAllUnsupported
+		}
+		keys, err := ls.Keys(keyPrefix)
+		if err != nil {
+			return 0, err
+		}
+		for _, key := range keys {
+			ids = append(ids, key[len(keyPrefix):])
+		}
+	}
+	encoder := json.NewEncoder(w)
+	exported := 0
+	for _, id := range ids {
+		content, err := s.Content(id)
+		if err != nil {
+			return exported, err
+		}
+		if content == nil {
+			if all {
+				continue // Expired since it was listed.
+			}
+			return exported, fmt.Errorf("Example not found: %s", id)
+		}
+		rev, err := s.LoadRevision(id)
+		if err != nil {
+			return exported, err
+		}
+		err = encoder.Encode(&BundleEntry{ID: id, Content: content, Revision: rev})
+		if err != nil {
+			return exported, err
+		}
+		exported++
+	}
+	return exported, nil
+}
+
+// Import the saved Examples from a bundle. The IDs must match the
+// content so existing links keep working. Returns the number of
+// Examples imported.
+func (s *Store) Import(r io.Reader) (int, error) {
+	scanner := bufio.NewScanner(r)
+	imported := 0
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry BundleEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return imported, fmt.Errorf("Invalid bundle entry on line %d: %s", line, err)
+		}
+		if ContentID(entry.Content) != entry.ID {
+			return imported, fmt.Errorf(
+				"Content does not match ID %s on line %d", entry.ID, line)
+		}
+		if err := s.Save(entry.ID, entry.Content); err != nil {
+			return imported, err
+		}
+		if entry.Revision != nil {
+			if entry.Revision.ID != entry.ID {
+				return imported, fmt.Errorf(
+					"Revision does not match ID %s on line %d", entry.ID, line)
+			}
+			if err := s.SaveRevision(entry.Revision); err != nil {
+				return imported, err
+			}
+		}
+		imported++
+	}
+	return imported, scanner.Err()
+}
@@ -0,0 +1,120 @@
+package examples
+
+import (
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/daaku/rell/examples/memstore"
+)
+
+func TestExportImport(t *testing.T) {
+	t.Parallel()
+	source := &Store{ByteStore: &memstore.Store{}}
+	content := []byte("<script>FB.api('/me')</script>")
+	id := ContentID(content)
+	if err := source.Save(id, content); err != nil {
+		t.Fatal(err)
+	}
+	rev := &Revision{ID: id, Created: time.Unix(1, 0).UTC()}
+	if err := source.SaveRevision(rev); err != nil {
+		t.Fatal(err)
+	}
+
+	var bundle bytes.Buffer
+	exported, err := source.Export(&bundle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported != 1 {
+		t.Fatalf("Was expecting 1 exported example instead found %d", exported)
+	}
+
+	dest := &Store{ByteStore: &memstore.Store{}}
+	imported, err := dest.Import(&bundle)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if imported != 1 {
+		t.Fatalf("Was expecting 1 imported example instead found %d", imported)
+	}
+	actual, err := dest.Content(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, content) {
+		t.Fatalf(`Did not find expected content "%s" instead found "%s"`, content, actual)
+	}
+	actualRev, err := dest.LoadRevision(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actualRev == nil || !actualRev.Created.Equal(rev.Created) {
+		t.Fatalf("Did not find expected revision %+v instead found %+v", rev, actualRev)
+	}
+}
+
+func TestImportMismatchedID(t *testing.T) {
+	t.Parallel()
+	s := &Store{ByteStore: &memstore.Store{}}
+	bundle := bytes.NewBufferString(`{"id":"abc","content":"aGVsbG8="}` + "\n")
+	if _, err := s.Import(bundle); err == nil {
+		t.Fatal("Was expecting an error for a mismatched ID.")
+	}
+}
+
+// A store that only provides the ByteStore methods.
+type plainStore struct {
+	ByteStore
+}
+
+func TestExportAllUnsupported(t *testing.T) {
+	t.Parallel()
+	s := &Store{ByteStore: plainStore{&memstore.Store{}}}
+	content := []byte("plain")
+	id := ContentID(content)
+	if err := s.Save(id, content); err != nil {
+		t.Fatal(err)
+	}
+	var bundle bytes.Buffer
+	if _, err := s.Export(&bundle, nil); err != errExportAllUnsupported {
+		t.Fatalf("Was expecting the export all error instead found %v", err)
+	}
+	if bundle.Len() != 0 {
+		t.Fatalf("Was not expecting any output, found %s", bundle.String())
+	}
+	exported, err := s.Export(&bundle, []string{id})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported != 1 {
+		t.Fatalf("Was expecting 1 exported example instead found %d", exported)
+	}
+}
+
+func TestExportSkipsExpired(t *testing.T) {
+	t.Parallel()
+	bs := &memstore.Store{}
+	s := &Store{ByteStore: bs, TTL: time.Hour}
+	for _, content := range []string{"fresh", "stale"} {
+		if err := s.Save(ContentID([]byte(content)), []byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stale := ContentID([]byte("stale"))
+	old := time.Now().Add(-2 * time.Hour).Unix()
+	bs.Store(makeAccessKey(stale), []byte(strconv.FormatInt(old, 10)))
+
+	var bundle bytes.Buffer
+	exported, err := s.Export(&bundle, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exported != 1 {
+		t.Fatalf("Was expecting 1 exported example instead found %d", exported)
+	}
+	if _, err := s.Export(&bundle, []string{stale}); err == nil {
+		t.Fatal("Was expecting an error exporting an expired example.")
+	}
+}
@@ -0,0 +1,100 @@
+// Package diskstore provides a file system backed examples.ByteStore
+// suitable for single box deployments.
+package diskstore
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const tmpPrefix = ".tmp-"
+
+// A store that keeps one file per key inside Dir.
+type Store struct {
+	Dir string
+}
+
+// Store the value for the given key. The value is written to a
+// temporary file first and then renamed into place, so concurrent
+// readers never see partial values.
+func (s *Store) Store(key string, value []byte) error {
+	if err := os.MkdirAll(s.Dir, 0755); err != nil {
+		return fmt.Errorf("Failed to create directory %s: %s", s.Dir, err)
+	}
+	tmp, err := ioutil.TempFile(s.Dir, tmpPrefix)
+	if err != nil {
+		return fmt.Errorf("Failed to create temporary file in %s: %s", s.Dir, err)
+	}
+	_, err = tmp.Write(value)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Failed to write temporary file %s: %s", tmp.Name(), err)
+	}
+	if err := os.Rename(tmp.Name(), s.filename(key)); err != nil {
+		os.Remove(tmp.Name())
+		return fmt.Errorf("Failed to store key %s: %s", key, err)
+	}
+	return nil
+}
+
+// Get the value for the given key. A nil value is returned if the key
+// does not exist.
+func (s *Store) Get(key string) ([]byte, error) {
+	value, err := ioutil.ReadFile(s.filename(key))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read key %s: %s", key, err)
+	}
+	return value, nil
+}
+
+// Get the sorted keys with the given prefix.
+func (s *Store) Keys(prefix string) ([]string, error) {
+	infos, err := ioutil.ReadDir(s.Dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read directory %s: %s", s.Dir, err)
+	}
+	var keys []string
+	for _, info := range infos {
+		if info.IsDir() || strings.HasPrefix(info.Name(), tmpPrefix) {
+			continue
+		}
+		key, err := url.QueryUnescape(info.Name())
+		if err != nil {
+			continue
+		}
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete the given key. Deleting a missing key is not an error.
+func (s *Store) Delete(key string) error {
+	err := os.Remove(s.filename(key))
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("Failed to delete key %s: %s", key, err)
+	}
+	return nil
+}
+
+// Keys may contain characters that are not safe in file names, so we
+// escape them.
+func (s *Store) filename(key string) string {
+	return filepath.Join(s.Dir, url.QueryEscape(key))
+}
@@ -0,0 +1,55 @@
+package diskstore_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/daaku/rell/examples"
+	"github.com/daaku/rell/examples/diskstore"
+)
+
+var _ examples.ByteStore = &diskstore.Store{}
+
+func tempStore(t *testing.T) *diskstore.Store {
+	dir, err := ioutil.TempDir("", "diskstore-test-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &diskstore.Store{Dir: filepath.Join(dir, "store")}
+}
+
+func TestStoreAndGet(t *testing.T) {
+	t.Parallel()
+	store := tempStore(t)
+	defer os.RemoveAll(filepath.Dir(store.Dir))
+	const key = "fbrell_examples:abc/../def"
+	if err := store.Store(key, []byte("hello")); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.Store(key, []byte("world")); err != nil {
+		t.Fatal(err)
+	}
+	actual, err := store.Get(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, []byte("world")) {
+		t.Fatalf(`Did not find expected value "world" instead found "%s"`, actual)
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	t.Parallel()
+	store := tempStore(t)
+	defer os.RemoveAll(filepath.Dir(store.Dir))
+	actual, err := store.Get("missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != nil {
+		t.Fatalf("Was expecting nil value instead found %v", actual)
+	}
+}
@@ -4,13 +4,19 @@ package examples
 import (
 	"bytes"
 	"crypto/md5"
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"log"
 	"net/http"
+	"net/url"
+	"os"
 	"path"
 	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/daaku/go.errcode"
 	"github.com/daaku/go.flag.pkgpath"
@@ -30,16 +36,51 @@ type ByteStore interface {
 	Get(key string) ([]byte, error)
 }
 
+// Implemented by ByteStores that can enumerate and delete keys, which
+// is required for reporting on and pruning saved examples.
+type ListByteStore interface {
+	ByteStore
+	Keys(prefix string) ([]string, error)
+	Delete(key string) error
+}
+
+// Implemented by ByteStores that can expire keys themselves, which lets
+// the Store TTL free saved examples without pruning.
+type ExpireByteStore interface {
+	ByteStore
+	Expire(key string, ttl time.Duration) error
+}
+
 type Store struct {
 	ByteStore ByteStore
+
+	// Saved examples not accessed within the TTL are treated as missing.
+	// Zero means they never expire.
+	TTL time.Duration
+
+	// The most recent saved examples seen by this process, for search.
+	saved Index
 }
 
 type Example struct {
-	Name    string `json:"-"`
-	Content []byte `json:"-"`
-	AutoRun bool   `json:"autoRun"`
-	Title   string `json:"-"`
-	URL     string `json:"-"`
+	Name        string   `json:"-"`
+	Content     []byte   `json:"-"`
+	AutoRun     bool     `json:"autoRun"`
+	Title       string   `json:"-"`
+	URL         string   `json:"-"`
+	Description string   `json:"-"`
+	Scope       []string `json:"scope,omitempty"`
+	Versions    []string `json:"-"`
+	Tags        []string `json:"-"`
+	Version     string   `json:"-"` // the DB this example belongs to
+}
+
+// A Revision records where a saved example came from.
+type Revision struct {
+	ID      string     `json:"id"`
+	Parent  string     `json:"parent,omitempty"`
+	Created time.Time  `json:"created"`
+	Values  url.Values `json:"values,omitempty"`
 }
 
 type Category struct {
@@ -51,6 +92,7 @@ type Category struct {
 type DB struct {
 	Category []*Category
 	Reverse  map[string]*Example
+	Index    *Index
 }
 
 var (
@@ -60,22 +102,55 @@ var (
 		"github.com/daaku/rell/examples/db/old",
 		"The directory containing examples for the old SDK.",
 	)
+	midExamplesDir = pkgpath.Dir(
+		"rell.examples.mid",
+		"github.com/daaku/rell/examples/db/mid",
+		"The directory containing examples for the mid SDK.",
+	)
 	newExamplesDir = pkgpath.Dir(
 		"rell.examples.new",
 		"github.com/daaku/rell/examples/db/mu",
 		"The directory containing examples for the new SDK.",
 	)
 
-	// We have two disk backed DBs.
-	old *DB
-	mu  *DB
+	// The SDK versions with disk backed DBs, newest first.
+	Versions = []string{"mu", "mid", "old"}
+
+	// The directory for each SDK version.
+	dirs = map[string]*string{
+		"mu":  newExamplesDir,
+		"mid": midExamplesDir,
+		"old": oldExamplesDir,
+	}
+
+	// Versions implied for Examples that do not declare any. The mid SDK
+	// used to be served the old DB, so old Examples work with it unless
+	// they say otherwise.
+	impliedVersions = map[string][]string{
+		"old": {"old", "mid"},
+	}
+
+	// The DBs by SDK version, including the Examples from the other
+	// versions which declare support for it. They are loaded on first
+	// use and swapped out as a whole on reload, so once a DB has been
+	// returned by GetDB it is never modified.
+	dbMutex sync.RWMutex
+	dbs     = make(map[string]*DB)
 
 	// Stock response for the index page.
 	emptyExample = &Example{Title: "Welcome", URL: "/", AutoRun: true}
+
+	// Limits how far back we walk when building the history for an
+	// example.
+	maxHistory = 50
+
+	// Limits the number of saved examples kept in the search index,
+	// evicting the least recently loaded or saved.
+	maxSavedIndex = 1000
 )
 
-// Loads a specific examples directory.
-func loadDir(name string) (*DB, error) {
+// Loads a specific examples directory for the given SDK version.
+func loadDir(name, version string) (*DB, error) {
 	categories, err := ioutil.ReadDir(name)
 	if err != nil {
 		return nil, fmt.Errorf("Failed to read directory %s: %s", name, err)
@@ -83,6 +158,7 @@ func loadDir(name string) (*DB, error) {
 	db := &DB{
 		Category: make([]*Category, 0, len(categories)),
 		Reverse:  make(map[string]*Example),
+		Index:    &Index{},
 	}
 	db.Reverse[ContentID(emptyExample.Content)] = emptyExample
 	for _, categoryFileInfo := range categories {
@@ -111,6 +187,11 @@ func loadDir(name string) (*DB, error) {
 				return nil, fmt.Errorf(
 					"Failed to read example %s: %s", exampleFile, err)
 			}
+			meta, content, err := parseFrontMatter(content)
+			if err != nil {
+				return nil, fmt.Errorf(
+					"Failed to parse front matter in %s: %s", exampleFile, err)
+			}
 			cleanName := exampleName[:len(exampleName)-5]
 			example := &Example{
 				Name:    cleanName,
@@ -118,9 +199,20 @@ func loadDir(name string) (*DB, error) {
 				AutoRun: true,
 				Title:   categoryName + " · " + cleanName,
 				URL:     path.Join("/", categoryName, cleanName),
+				Version: version,
+			}
+			if meta != nil {
+				meta.apply(example)
+			}
+			if len(example.Versions) == 0 {
+				example.Versions = append([]string(nil), impliedVersions[version]...)
+			}
+			if !example.SupportsVersion(version) {
+				example.Versions = append([]string{version}, example.Versions...)
 			}
 			category.Example = append(category.Example, example)
 			db.Reverse[ContentID(bytes.TrimSpace(content))] = example
+			db.Index.Add(categoryName, category.Hidden, example)
 		}
 		db.Category = append(db.Category, category)
 	}
@@ -150,42 +242,228 @@ func (s *Store) Load(version, path string) (*Example, error) {
 			return nil, errcode.New(
 				http.StatusNotFound, "Example not found: %s", path)
 		}
+		expired, err := s.expired(parts[2], true)
+		if err != nil {
+			return nil, err
+		}
+		if expired {
+			return nil, errcode.New(
+				http.StatusNotFound, "Example has expired: %s", path)
+		}
+		s.indexSaved(parts[2], content)
 		return &Example{
 			Content: content,
 			Title:   "Stored Example",
 			URL:     path,
 		}, nil
 	}
-	category := GetDB(version).FindCategory(parts[1])
-	if category == nil {
-		return nil, errcode.New(http.StatusNotFound, "Could not find category: %s", parts[1])
-	}
-	example := category.FindExample(parts[2])
+	example := FindExample(version, parts[1], parts[2])
 	if example == nil {
-		return nil, errcode.New(http.StatusNotFound, "Could not find example: %s", parts[2])
+		return nil, errcode.New(http.StatusNotFound, "Could not find example: %s", path)
 	}
 	return example, nil
 }
 
-// Get the DB for a given SDK Version.
-func GetDB(version string) *DB {
-	var err error
-	if version == "mu" {
-		if mu == nil {
-			mu, err = loadDir(*newExamplesDir)
-			if err != nil {
-				log.Fatal(err)
+// Find an Example for the SDK version by category and name. This
+// includes Examples from the other DBs which declare support for the
+// version.
+func FindExample(version, categoryName, name string) *Example {
+	if category := GetDB(version).FindCategory(categoryName); category != nil {
+		return category.FindExample(name)
+	}
+	return nil
+}
+
+// A row in the compatibility listing.
+type Compat struct {
+	Category string
+	Name     string
+	Hidden   bool
+
+	// The Example served for each SDK version, if any.
+	Example map[string]*Example
+}
+
+// Build the compatibility listing showing which Examples are available
+// for each SDK version.
+func Compatibility() []*Compat {
+	var rows []*Compat
+	seen := make(map[string]bool)
+	for _, version := range Versions {
+		for _, category := range GetDB(version).Category {
+			for _, example := range category.Example {
+				key := category.Name + "/" + example.Name
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				row := &Compat{
+					Category: category.Name,
+					Name:     example.Name,
+					Hidden:   category.Hidden,
+					Example:  make(map[string]*Example),
+				}
+				for _, v := range Versions {
+					if e := FindExample(v, category.Name, example.Name); e != nil {
+						row.Example[v] = e
+					}
+				}
+				rows = append(rows, row)
 			}
 		}
-		return mu
 	}
-	if old == nil {
-		old, err = loadDir(*oldExamplesDir)
+	return rows
+}
+
+// Get the DB for a given SDK Version. Unknown versions get the old DB.
+func GetDB(version string) *DB {
+	if _, ok := dirs[version]; !ok {
+		version = "old"
+	}
+	dbMutex.RLock()
+	db := dbs[version]
+	dbMutex.RUnlock()
+	if db != nil {
+		return db
+	}
+
+	dbMutex.Lock()
+	defer dbMutex.Unlock()
+	if db = dbs[version]; db == nil {
+		loaded, err := loadAll()
 		if err != nil {
 			log.Fatal(err)
 		}
+		dbs = loaded
+		db = dbs[version]
+	}
+	return db
+}
+
+// Reload the disk backed DBs. The existing DBs are only replaced if all
+// of them load successfully. Requests already holding a DB continue to
+// use it.
+func Reload() error {
+	loaded, err := loadAll()
+	if err != nil {
+		return err
+	}
+	dbMutex.Lock()
+	dbs = loaded
+	dbMutex.Unlock()
+	return nil
+}
+
+// Load all the disk backed DBs, and merge in the Examples each version
+// gets from the others.
+func loadAll() (map[string]*DB, error) {
+	own := make(map[string]*DB)
+	for _, version := range Versions {
+		db, err := loadDir(*dirs[version], version)
+		if err != nil {
+			return nil, err
+		}
+		own[version] = db
+	}
+	merged := make(map[string]*DB)
+	for _, version := range Versions {
+		merged[version] = mergeDB(version, own)
+	}
+	return merged, nil
+}
+
+// Build the DB for the version from it's own Examples, followed by those
+// from the other DBs which declare support for it.
+func mergeDB(version string, own map[string]*DB) *DB {
+	db := &DB{
+		Reverse: make(map[string]*Example),
+		Index:   &Index{},
+	}
+	db.Reverse[ContentID(emptyExample.Content)] = emptyExample
+	for _, source := range append([]string{version}, Versions...) {
+		for _, sourceCategory := range own[source].Category {
+			category := db.FindCategory(sourceCategory.Name)
+			if category == nil {
+				category = &Category{
+					Name:   sourceCategory.Name,
+					Hidden: sourceCategory.Hidden,
+				}
+				db.Category = append(db.Category, category)
+			}
+			for _, example := range sourceCategory.Example {
+				if !example.SupportsVersion(version) || category.FindExample(example.Name) != nil {
+					continue
+				}
+				category.Example = append(category.Example, example)
+				db.Reverse[ContentID(bytes.TrimSpace(example.Content))] = example
+				db.Index.Add(category.Name, category.Hidden, example)
+			}
+		}
+	}
+	sort.Sort(byCategoryName(db.Category))
+	for _, category := range db.Category {
+		sort.Sort(byExampleName(category.Example))
+	}
+	return db
+}
+
+type byCategoryName []*Category
+
+func (s byCategoryName) Len() int           { return len(s) }
+func (s byCategoryName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byCategoryName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+type byExampleName []*Example
+
+func (s byExampleName) Len() int           { return len(s) }
+func (s byExampleName) Swap(i, j int)      { s[i], s[j] = s[j], s[i] }
+func (s byExampleName) Less(i, j int) bool { return s[i].Name < s[j].Name }
+
+// Periodically rescan the examples directories and Reload when any
+// file has been added, removed or modified. This never returns, so it
+// should be run in it's own goroutine.
+func Watch(interval time.Duration) {
+	last, err := fingerprint()
+	if err != nil {
+		log.Printf("Failed to scan examples directories: %s", err)
+	}
+	for {
+		time.Sleep(interval)
+		current, err := fingerprint()
+		if err != nil {
+			log.Printf("Failed to scan examples directories: %s", err)
+			continue
+		}
+		if current == last {
+			continue
+		}
+		if err := Reload(); err != nil {
+			log.Printf("Failed to reload examples: %s", err)
+			continue
+		}
+		log.Printf("Reloaded examples.")
+		last = current
+	}
+}
+
+// Computes a hash of the names, sizes and modification times of all
+// the files in the examples directories.
+func fingerprint() (string, error) {
+	h := md5.New()
+	for _, version := range Versions {
+		dir := *dirs[version]
+		err := filepath.Walk(dir, func(name string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(h, "%s %d %d\n", name, info.Size(), info.ModTime().UnixNano())
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
 	}
-	return old
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
 }
 
 // Find a category by it's name.
@@ -198,6 +476,21 @@ func (d *DB) FindCategory(name string) *Category {
 	return nil
 }
 
+// Check if the category is hidden from the listing.
+func IsHidden(category string) bool {
+	return hidden[category]
+}
+
+// Check if the Example supports the given SDK version.
+func (e *Example) SupportsVersion(version string) bool {
+	for _, v := range e.Versions {
+		if v == version {
+			return true
+		}
+	}
+	return false
+}
+
 // Find an example by it's name.
 func (c *Category) FindExample(name string) *Example {
 	for _, example := range c.Example {
@@ -217,13 +510,126 @@ func (s *Store) Save(id string, content []byte) error {
 	}
 	err := s.ByteStore.Store(makeKey(id), content)
 	if err != nil {
-		log.Printf("Error in ByteStore.Store: %s", err)
+		return err
+	}
+	if err := s.touch(id, time.Now()); err != nil {
+		return err
+	}
+	s.indexSaved(id, content)
+	return nil
+}
+
+// Search the stock Examples for the given SDK version. Saved Examples
+// seen by this process are also included, but since they are unlisted
+// they are treated as hidden.
+func (s *Store) Search(version, query string, includeHidden bool) []*SearchResult {
+	results := GetDB(version).Index.Search(query, includeHidden)
+	if includeHidden {
+		results = append(results, s.saved.Search(query, true)...)
+		sort.Sort(byScore(results))
 	}
-	return err
+	return results
+}
+
+func (s *Store) indexSaved(id string, content []byte) {
+	s.saved.add("saved", true, &Example{
+		Name:    id,
+		Content: content,
+		Title:   "Stored Example",
+		URL:     "/saved/" + id,
+	}, maxSavedIndex)
+}
+
+// Save the Revision for an Example. Since examples are content
+// addressed, the first Revision recorded for an ID wins.
+func (s *Store) SaveRevision(rev *Revision) error {
+	existing, err := s.LoadRevision(rev.ID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return nil
+	}
+	encoded, err := json.Marshal(rev)
+	if err != nil {
+		return err
+	}
+	if err := s.ByteStore.Store(makeRevisionKey(rev.ID), encoded); err != nil {
+		return err
+	}
+	return s.expire(rev.ID)
+}
+
+// Load the Revision for an Example. Returns nil if none was recorded.
+func (s *Store) LoadRevision(id string) (*Revision, error) {
+	encoded, err := s.ByteStore.Get(makeRevisionKey(id))
+	if err != nil {
+		return nil, err
+	}
+	if encoded == nil {
+		return nil, nil
+	}
+	rev := new(Revision)
+	if err := json.Unmarshal(encoded, rev); err != nil {
+		return nil, fmt.Errorf("Failed to decode revision %s: %s", id, err)
+	}
+	return rev, nil
+}
+
+// Load the lineage of a saved Example, starting with the given ID and
+// walking back through its parents. Examples saved without a Revision
+// are included with only their ID.
+func (s *Store) History(id string) ([]*Revision, error) {
+	var history []*Revision
+	seen := make(map[string]bool)
+	for id != "" && !seen[id] && len(history) < maxHistory {
+		seen[id] = true
+		rev, err := s.LoadRevision(id)
+		if err != nil {
+			return nil, err
+		}
+		if rev == nil {
+			rev = &Revision{ID: id}
+		}
+		history = append(history, rev)
+		id = rev.Parent
+	}
+	return history, nil
+}
+
+// Load the content of a saved Example. Returns nil if it does not
+// exist or has expired. Unlike Load this does not count as an access.
+func (s *Store) Content(id string) ([]byte, error) {
+	content, err := s.ByteStore.Get(makeKey(id))
+	if err != nil || content == nil {
+		return nil, err
+	}
+	expired, err := s.expired(id, false)
+	if err != nil || expired {
+		return nil, err
+	}
+	return content, nil
+}
+
+// Check if the given string looks like an ID generated by ContentID.
+func IsContentID(id string) bool {
+	if len(id) != md5.Size*2 {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
 }
 
 func makeKey(id string) string {
-	return "fbrell_examples:" + id
+	return keyPrefix + id
+}
+
+func makeRevisionKey(id string) string {
+	return "fbrell_examples_revision:" + id
 }
 
 func ContentID(content []byte) string {
@@ -0,0 +1,56 @@
+package examples
+
+import (
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/daaku/rell/context"
+)
+
+var testDirsOnce sync.Once
+
+// Use the examples in the source tree when the flags did not resolve.
+func useTestDirs() {
+	testDirsOnce.Do(func() {
+		for version, dir := range dirs {
+			if dir == nil || *dir == "" {
+				local := filepath.Join("db", version)
+				dirs[version] = &local
+			}
+		}
+	})
+}
+
+func TestGetDBMidIncludesOld(t *testing.T) {
+	useTestDirs()
+	db := GetDB(context.Mid)
+	cases := []struct {
+		category, name, version string
+	}{
+		{"connect", "logout", "mid"},
+		{"api", "call-method", "old"},
+		{"xfbml", "fb:login-button", "old"},
+	}
+	for _, c := range cases {
+		category := db.FindCategory(c.category)
+		if category == nil {
+			t.Fatalf("Did not find expected category %s in the mid DB", c.category)
+		}
+		example := category.FindExample(c.name)
+		if example == nil || example.Version != c.version {
+			t.Fatalf("Did not find expected %s example %s/%s in the mid DB, found %+v",
+				c.version, c.category, c.name, example)
+		}
+		if FindExample(context.Mid, c.category, c.name) != example {
+			t.Fatalf("Did not find expected example %s/%s by URL", c.category, c.name)
+		}
+	}
+}
+
+func TestGetDBMuExcludesOld(t *testing.T) {
+	useTestDirs()
+	if example := FindExample(context.Mu, "api", "call-method"); example != nil {
+		t.Fatalf("Was not expecting an old example in the mu DB, found %+v", example)
+	}
+}
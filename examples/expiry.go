@@ -0,0 +1,203 @@
+package examples
+
+import (
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/daaku/go.errcode"
+)
+
+const (
+	keyPrefix       = "fbrell_examples:"
+	accessKeyPrefix = "fbrell_examples_access:"
+
+	// Access times are only rewritten when they are older than this, which
+	// avoids a write for every view of a popular example.
+	accessResolution = time.Hour
+)
+
+var errListUnsupported = errcode.New(
+	http.StatusNotImplemented,
+	"The configured store does not support listing saved examples.")
+
+// Size information about the saved examples.
+type StoreStats struct {
+	Examples int   `json:"examples"`
+	Bytes    int64 `json:"bytes"`
+	Expired  int   `json:"expired"`
+}
+
+// Report the number and size of the saved examples.
+func (s *Store) Stats() (*StoreStats, error) {
+	ls, err := s.listStore()
+	if err != nil {
+		return nil, err
+	}
+	keys, err := ls.Keys(keyPrefix)
+	if err != nil {
+		return nil, err
+	}
+	stats := &StoreStats{}
+	for _, key := range keys {
+		content, err := ls.Get(key)
+		if err != nil {
+			return nil, err
+		}
+		if content == nil {
+			continue
+		}
+		stats.Examples++
+		stats.Bytes += int64(len(content))
+		accessed, err := s.accessed(key[len(keyPrefix):])
+		if err != nil {
+			return nil, err
+		}
+		if s.isExpired(accessed, time.Now()) {
+			stats.Expired++
+		}
+	}
+	return stats, nil
+}
+
+// Delete saved examples that have not been accessed within maxAge and
+// return the number deleted. Examples with no recorded access time are
+// treated as accessed now.
+func (s *Store) Prune(maxAge time.Duration) (int, error) {
+	ls, err := s.listStore()
+	if err != nil {
+		return 0, err
+	}
+	keys, err := ls.Keys(keyPrefix)
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now()
+	pruned := 0
+	for _, key := range keys {
+		id := key[len(keyPrefix):]
+		accessed, err := s.accessed(id)
+		if err != nil {
+			return pruned, err
+		}
+		if accessed.IsZero() {
+			if err := s.touch(id, now); err != nil {
+				return pruned, err
+			}
+			continue
+		}
+		if now.Sub(accessed) <= maxAge {
+			continue
+		}
+		if err := s.delete(ls, id); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+	return pruned, nil
+}
+
+// Delete all the keys for a saved example.
+func (s *Store) delete(ls ListByteStore, id string) error {
+	for _, key := range exampleKeys(id) {
+		if err := ls.Delete(key); err != nil {
+			return err
+		}
+	}
+	s.saved.Remove("/saved/" + id)
+	return nil
+}
+
+func (s *Store) listStore() (ListByteStore, error) {
+	ls, ok := s.ByteStore.(ListByteStore)
+	if !ok {
+		return nil, errListUnsupported
+	}
+	return ls, nil
+}
+
+// Check if the saved example has expired, refreshing it's access time
+// if it has not and refresh is set. Examples with no recorded access
+// time never expire. Expired examples are deleted if the store supports
+// it.
+func (s *Store) expired(id string, refresh bool) (bool, error) {
+	accessed, err := s.accessed(id)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	if s.isExpired(accessed, now) {
+		if ls, ok := s.ByteStore.(ListByteStore); ok {
+			if err := s.delete(ls, id); err != nil {
+				log.Printf("Ignoring error deleting expired example %s: %s", id, err)
+			}
+		}
+		return true, nil
+	}
+	if refresh && now.Sub(accessed) > accessResolution {
+		if err := s.touch(id, now); err != nil {
+			log.Printf("Ignoring error recording access for example %s: %s", id, err)
+		}
+	}
+	return false, nil
+}
+
+// Check if an example last accessed at the given time has expired.
+func (s *Store) isExpired(accessed, now time.Time) bool {
+	return s.TTL > 0 && !accessed.IsZero() && now.Sub(accessed) > s.TTL
+}
+
+// Get the last access time for a saved example. The zero time is
+// returned if it is not known.
+func (s *Store) accessed(id string) (time.Time, error) {
+	raw, err := s.ByteStore.Get(makeAccessKey(id))
+	if err != nil {
+		return time.Time{}, err
+	}
+	if raw == nil {
+		return time.Time{}, nil
+	}
+	unix, err := strconv.ParseInt(string(raw), 10, 64)
+	if err != nil {
+		log.Printf("Ignoring invalid access time for example %s: %s", id, raw)
+		return time.Time{}, nil
+	}
+	return time.Unix(unix, 0), nil
+}
+
+// Record an access for a saved example. If the store can expire keys,
+// they are set to expire once the TTL has passed since this access.
+func (s *Store) touch(id string, now time.Time) error {
+	err := s.ByteStore.Store(
+		makeAccessKey(id), []byte(strconv.FormatInt(now.Unix(), 10)))
+	if err != nil {
+		return err
+	}
+	return s.expire(id)
+}
+
+// Set the keys for a saved example to expire once the TTL has passed, if
+// the store can expire keys. Keys that do not exist yet are unaffected, so
+// this is also done when they are written.
+func (s *Store) expire(id string) error {
+	es, ok := s.ByteStore.(ExpireByteStore)
+	if !ok || s.TTL <= 0 {
+		return nil
+	}
+	for _, key := range exampleKeys(id) {
+		if err := es.Expire(key, s.TTL); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func makeAccessKey(id string) string {
+	return accessKeyPrefix + id
+}
+
+// All the keys used for a saved example.
+func exampleKeys(id string) []string {
+	return []string{makeKey(id), makeRevisionKey(id), makeAccessKey(id)}
+}
@@ -0,0 +1,135 @@
+package examples
+
+import (
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/daaku/rell/examples/memstore"
+)
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+	bs := &memstore.Store{}
+	s := &Store{ByteStore: bs}
+	if err := s.Save("fresh", []byte("fresh")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Save("stale", []byte("stale")); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-10 * 24 * time.Hour)
+	if err := s.touch("stale", old); err != nil {
+		t.Fatal(err)
+	}
+
+	pruned, err := s.Prune(7 * 24 * time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pruned != 1 {
+		t.Fatalf("Was expecting 1 pruned example instead found %d", pruned)
+	}
+	stats, err := s.Stats()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Examples != 1 || stats.Bytes != int64(len("fresh")) {
+		t.Fatalf("Did not find expected stats instead found %+v", stats)
+	}
+}
+
+func TestExpiredLoad(t *testing.T) {
+	t.Parallel()
+	bs := &memstore.Store{}
+	s := &Store{ByteStore: bs, TTL: time.Hour}
+	if err := s.Save("abc", []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Load("mu", "/saved/abc"); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour).Unix()
+	bs.Store(makeAccessKey("abc"), []byte(strconv.FormatInt(old, 10)))
+	_, err := s.Load("mu", "/saved/abc")
+	if code, ok := err.(interface {
+		Code() int
+	}); !ok || code.Code() != http.StatusNotFound {
+		t.Fatalf("Was expecting a not found error instead found %v", err)
+	}
+}
+
+func TestExpiredLoadDeletes(t *testing.T) {
+	t.Parallel()
+	bs := &memstore.Store{}
+	s := &Store{ByteStore: bs, TTL: time.Hour}
+	if err := s.Save("abc", []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour).Unix()
+	bs.Store(makeAccessKey("abc"), []byte(strconv.FormatInt(old, 10)))
+	if _, err := s.Load("mu", "/saved/abc"); err == nil {
+		t.Fatal("Was expecting an error loading an expired example")
+	}
+	keys, err := bs.Keys("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 0 {
+		t.Fatalf("Was expecting the expired example to be deleted, found %v", keys)
+	}
+}
+
+// A store that records expiries, which like redis only apply to keys
+// that exist.
+type expireStore struct {
+	ListByteStore
+	expires map[string]time.Duration
+}
+
+func (e *expireStore) Expire(key string, ttl time.Duration) error {
+	value, err := e.Get(key)
+	if err != nil || value == nil {
+		return err
+	}
+	e.expires[key] = ttl
+	return nil
+}
+
+func TestSaveExpires(t *testing.T) {
+	t.Parallel()
+	bs := &expireStore{
+		ListByteStore: &memstore.Store{},
+		expires:       make(map[string]time.Duration),
+	}
+	s := &Store{ByteStore: bs, TTL: time.Hour}
+	if err := s.Save("abc", []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SaveRevision(&Revision{ID: "abc"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range exampleKeys("abc") {
+		if bs.expires[key] != time.Hour {
+			t.Fatalf("Did not find expected expiry for %s in %v", key, bs.expires)
+		}
+	}
+}
+
+func TestExpiredContent(t *testing.T) {
+	t.Parallel()
+	bs := &memstore.Store{}
+	s := &Store{ByteStore: bs, TTL: time.Hour}
+	if err := s.Save("abc", []byte("abc")); err != nil {
+		t.Fatal(err)
+	}
+	if content, err := s.Content("abc"); err != nil || string(content) != "abc" {
+		t.Fatalf("Did not find expected content instead found %s %v", content, err)
+	}
+	old := time.Now().Add(-2 * time.Hour).Unix()
+	bs.Store(makeAccessKey("abc"), []byte(strconv.FormatInt(old, 10)))
+	if content, err := s.Content("abc"); err != nil || content != nil {
+		t.Fatalf("Was expecting no content for an expired example, found %s %v", content, err)
+	}
+}
@@ -0,0 +1,112 @@
+package examples
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var (
+	frontMatterStart = []byte("<!--\n")
+	frontMatterEnd   = []byte("\n-->")
+)
+
+// Examples may optionally start with a front matter block, which is an
+// HTML comment containing "key: value" lines:
+//
+//	<!--
+//	title: Photo Albums
+//	description: Lists the albums for the current user.
+//	scope: user_photos, friends_photos
+//	versions: mu, mid
+//	tags: graph, photos
+//	autorun: false
+//	-->
+//
+// A comment containing anything other than the known keys is left
+// alone and treated as part of the example.
+type frontMatter struct {
+	Title       string
+	Description string
+	Scope       []string
+	Versions    []string
+	Tags        []string
+	AutoRun     *bool
+}
+
+// Apply the front matter to the Example.
+func (f *frontMatter) apply(e *Example) {
+	if f.Title != "" {
+		e.Title = f.Title
+	}
+	e.Description = f.Description
+	e.Scope = f.Scope
+	e.Versions = f.Versions
+	e.Tags = f.Tags
+	if f.AutoRun != nil {
+		e.AutoRun = *f.AutoRun
+	}
+}
+
+// Parse the front matter if present, returning it along with the
+// remaining content. A nil frontMatter is returned if the content does
+// not start with a front matter block.
+func parseFrontMatter(content []byte) (*frontMatter, []byte, error) {
+	normalized := bytes.Replace(content, []byte("\r\n"), []byte("\n"), -1)
+	if !bytes.HasPrefix(normalized, frontMatterStart) {
+		return nil, content, nil
+	}
+	end := bytes.Index(normalized, frontMatterEnd)
+	if end == -1 {
+		return nil, content, nil
+	}
+	block := normalized[len(frontMatterStart):end]
+	rest := bytes.TrimLeft(normalized[end+len(frontMatterEnd):], "\n")
+
+	f := &frontMatter{}
+	for _, line := range strings.Split(string(block), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		colon := strings.Index(line, ":")
+		if colon == -1 {
+			return nil, content, nil
+		}
+		key := strings.ToLower(strings.TrimSpace(line[:colon]))
+		value := strings.TrimSpace(line[colon+1:])
+		switch key {
+		default:
+			return nil, content, nil
+		case "title":
+			f.Title = value
+		case "description":
+			f.Description = value
+		case "scope":
+			f.Scope = splitList(value)
+		case "versions":
+			f.Versions = splitList(strings.ToLower(value))
+		case "tags":
+			f.Tags = splitList(value)
+		case "autorun":
+			autoRun, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("Invalid autorun value %q", value)
+			}
+			f.AutoRun = &autoRun
+		}
+	}
+	return f, rest, nil
+}
+
+// Split a comma separated list.
+func splitList(value string) []string {
+	var list []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			list = append(list, item)
+		}
+	}
+	return list
+}
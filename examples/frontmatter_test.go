@@ -0,0 +1,54 @@
+package examples
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	t.Parallel()
+	content := []byte("<!--\ntitle: Albums\nscope: user_photos, friends_photos\nautorun: false\n-->\n<ul></ul>\n")
+	meta, rest, err := parseFrontMatter(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta == nil {
+		t.Fatal("Was expecting front matter.")
+	}
+	if string(rest) != "<ul></ul>\n" {
+		t.Fatalf(`Did not find expected content "<ul></ul>" instead found "%s"`, rest)
+	}
+	example := &Example{AutoRun: true}
+	meta.apply(example)
+	expected := &Example{
+		Title:   "Albums",
+		Scope:   []string{"user_photos", "friends_photos"},
+		AutoRun: false,
+	}
+	if !reflect.DeepEqual(expected, example) {
+		t.Fatalf("Did not find expected example %+v instead found %+v", expected, example)
+	}
+}
+
+func TestParseFrontMatterPlainComment(t *testing.T) {
+	t.Parallel()
+	content := []byte("<!--\nthis is just a comment\n-->\n<ul></ul>")
+	meta, rest, err := parseFrontMatter(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if meta != nil {
+		t.Fatalf("Was not expecting front matter instead found %+v", meta)
+	}
+	if string(rest) != string(content) {
+		t.Fatalf(`Was expecting unchanged content instead found "%s"`, rest)
+	}
+}
+
+func TestParseFrontMatterInvalidAutoRun(t *testing.T) {
+	t.Parallel()
+	_, _, err := parseFrontMatter([]byte("<!--\nautorun: maybe\n-->\n"))
+	if err == nil {
+		t.Fatal("Was expecting an error.")
+	}
+}
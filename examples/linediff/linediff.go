@@ -0,0 +1,78 @@
+// Package linediff computes line oriented differences between two
+// texts. It is meant for small inputs like saved examples and uses the
+// simple quadratic longest common subsequence algorithm.
+package linediff
+
+import (
+	"strings"
+)
+
+// The operation for a Line.
+type Op int
+
+const (
+	Equal Op = iota
+	Insert
+	Delete
+)
+
+// A single line in the difference.
+type Line struct {
+	Op   Op
+	Text string
+}
+
+// Compute the lines needed to transform a into b.
+func Diff(a, b string) []Line {
+	al := split(a)
+	bl := split(b)
+
+	// lcs[i][j] is the length of the longest common subsequence of al[i:]
+	// and bl[j:].
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	lines := make([]Line, 0, len(al)+len(bl))
+	i, j := 0, 0
+	for i < len(al) && j < len(bl) {
+		switch {
+		case al[i] == bl[j]:
+			lines = append(lines, Line{Op: Equal, Text: al[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			lines = append(lines, Line{Op: Delete, Text: al[i]})
+			i++
+		default:
+			lines = append(lines, Line{Op: Insert, Text: bl[j]})
+			j++
+		}
+	}
+	for ; i < len(al); i++ {
+		lines = append(lines, Line{Op: Delete, Text: al[i]})
+	}
+	for ; j < len(bl); j++ {
+		lines = append(lines, Line{Op: Insert, Text: bl[j]})
+	}
+	return lines
+}
+
+func split(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,33 @@
+package linediff_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/daaku/rell/examples/linediff"
+)
+
+func TestDiff(t *testing.T) {
+	t.Parallel()
+	a := "one\ntwo\nthree\n"
+	b := "one\nthree\nfour\n"
+	expected := []linediff.Line{
+		{linediff.Equal, "one"},
+		{linediff.Delete, "two"},
+		{linediff.Equal, "three"},
+		{linediff.Insert, "four"},
+	}
+	actual := linediff.Diff(a, b)
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Did not find expected diff %+v instead found %+v", expected, actual)
+	}
+}
+
+func TestDiffEmpty(t *testing.T) {
+	t.Parallel()
+	actual := linediff.Diff("", "one")
+	expected := []linediff.Line{{linediff.Insert, "one"}}
+	if !reflect.DeepEqual(expected, actual) {
+		t.Fatalf("Did not find expected diff %+v instead found %+v", expected, actual)
+	}
+}
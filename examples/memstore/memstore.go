@@ -0,0 +1,67 @@
+// Package memstore provides an in-memory examples.ByteStore. It is
+// primarily intended for tests and local development where a redis
+// server is not available.
+package memstore
+
+import (
+	"sort"
+	"strings"
+	"sync"
+)
+
+// An in-memory store. The zero value is ready to use.
+type Store struct {
+	mutex sync.RWMutex
+	data  map[string][]byte
+}
+
+// Store a copy of the value for the given key.
+func (s *Store) Store(key string, value []byte) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if s.data == nil {
+		s.data = make(map[string][]byte)
+	}
+	s.data[key] = dup(value)
+	return nil
+}
+
+// Get a copy of the value for the given key. A nil value is returned if
+// the key does not exist.
+func (s *Store) Get(key string) ([]byte, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	value, ok := s.data[key]
+	if !ok {
+		return nil, nil
+	}
+	return dup(value), nil
+}
+
+// Get the sorted keys with the given prefix.
+func (s *Store) Keys(prefix string) ([]string, error) {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	var keys []string
+	for key := range s.data {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete the given key. Deleting a missing key is not an error.
+func (s *Store) Delete(key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.data, key)
+	return nil
+}
+
+func dup(b []byte) []byte {
+	c := make([]byte, len(b))
+	copy(c, b)
+	return c
+}
@@ -0,0 +1,40 @@
+package memstore_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/daaku/rell/examples"
+	"github.com/daaku/rell/examples/memstore"
+)
+
+var _ examples.ByteStore = &memstore.Store{}
+
+func TestStoreAndGet(t *testing.T) {
+	t.Parallel()
+	store := &memstore.Store{}
+	value := []byte("hello")
+	if err := store.Store("key", value); err != nil {
+		t.Fatal(err)
+	}
+	value[0] = 'j'
+	actual, err := store.Get("key")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(actual, []byte("hello")) {
+		t.Fatalf(`Did not find expected value "hello" instead found "%s"`, actual)
+	}
+}
+
+func TestGetMissing(t *testing.T) {
+	t.Parallel()
+	store := &memstore.Store{}
+	actual, err := store.Get("missing")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual != nil {
+		t.Fatalf("Was expecting nil value instead found %v", actual)
+	}
+}
@@ -0,0 +1,112 @@
+// Package redisstore provides a redis backed examples.ListByteStore and
+// examples.ExpireByteStore. Values are read and written by the go.redis
+// bytestore, keys are enumerated using SCAN.
+package redisstore
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/daaku/go.redis"
+	"github.com/daaku/go.redis/bytestore"
+)
+
+// Number of keys requested per SCAN call.
+const scanCount = "1000"
+
+// The redis client calls used, provided by *redis.Client.
+type Caller interface {
+	Call(args ...interface{}) (*redis.Reply, error)
+}
+
+type Store struct {
+	Bytes  *bytestore.Store
+	Client Caller
+}
+
+// Create a new Store using the client.
+func New(client *redis.Client) *Store {
+	return &Store{
+		Bytes:  bytestore.New(client),
+		Client: client,
+	}
+}
+
+// Store the value for the given key.
+func (s *Store) Store(key string, value []byte) error {
+	return s.Bytes.Store(key, value)
+}
+
+// Get the value for the given key.
+func (s *Store) Get(key string) ([]byte, error) {
+	return s.Bytes.Get(key)
+}
+
+// Get the sorted keys with the given prefix. SCAN is used rather than
+// KEYS to avoid blocking the server.
+func (s *Store) Keys(prefix string) ([]string, error) {
+	seen := make(map[string]bool)
+	var keys []string
+	cursor := "0"
+	for {
+		reply, err := s.Client.Call(
+			"SCAN", cursor, "MATCH", escapeGlob(prefix)+"*", "COUNT", scanCount)
+		if err != nil {
+			return nil, fmt.Errorf("Failed to scan keys with prefix %s: %s", prefix, err)
+		}
+		if len(reply.Elems) != 2 {
+			return nil, fmt.Errorf("Unexpected SCAN reply for prefix %s", prefix)
+		}
+		for _, elem := range reply.Elems[1].Elems {
+			// SCAN may return a key more than once.
+			key := elem.Elem.String()
+			if !seen[key] {
+				seen[key] = true
+				keys = append(keys, key)
+			}
+		}
+		cursor = reply.Elems[0].Elem.String()
+		if cursor == "0" {
+			break
+		}
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Delete the given key. Deleting a missing key is not an error.
+func (s *Store) Delete(key string) error {
+	if _, err := s.Client.Call("DEL", key); err != nil {
+		return fmt.Errorf("Failed to delete key %s: %s", key, err)
+	}
+	return nil
+}
+
+// Expire the given key after ttl, replacing any existing expiry.
+func (s *Store) Expire(key string, ttl time.Duration) error {
+	seconds := int64(ttl / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	_, err := s.Client.Call("EXPIRE", key, strconv.FormatInt(seconds, 10))
+	if err != nil {
+		return fmt.Errorf("Failed to expire key %s: %s", key, err)
+	}
+	return nil
+}
+
+// Escape the glob special characters used by MATCH.
+func escapeGlob(s string) string {
+	var b bytes.Buffer
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteRune('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
@@ -0,0 +1,84 @@
+package redisstore
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/daaku/go.redis"
+
+	"github.com/daaku/rell/examples"
+)
+
+var _ examples.ListByteStore = &Store{}
+var _ examples.ExpireByteStore = &Store{}
+
+// Replies with canned SCAN pages and records the calls.
+type fakeCaller struct {
+	pages [][]string
+	calls [][]interface{}
+}
+
+func reply(values ...string) *redis.Reply {
+	r := &redis.Reply{}
+	for _, v := range values {
+		r.Elems = append(r.Elems, &redis.Reply{Elem: redis.Elem(v)})
+	}
+	return r
+}
+
+func (f *fakeCaller) Call(args ...interface{}) (*redis.Reply, error) {
+	f.calls = append(f.calls, args)
+	if args[0] != "SCAN" {
+		return &redis.Reply{}, nil
+	}
+	page := f.pages[0]
+	f.pages = f.pages[1:]
+	return &redis.Reply{Elems: []*redis.Reply{
+		{Elem: redis.Elem(page[0])},
+		reply(page[1:]...),
+	}}, nil
+}
+
+func TestKeys(t *testing.T) {
+	t.Parallel()
+	caller := &fakeCaller{pages: [][]string{
+		{"7", "fbrell_examples:b", "fbrell_examples:a"},
+		{"0", "fbrell_examples:a", "fbrell_examples:c"},
+	}}
+	s := &Store{Client: caller}
+	keys, err := s.Keys("fbrell_examples:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"fbrell_examples:a", "fbrell_examples:b", "fbrell_examples:c"}
+	if !reflect.DeepEqual(keys, expected) {
+		t.Fatalf("Did not find expected keys %v instead found %v", expected, keys)
+	}
+	if len(caller.calls) != 2 || caller.calls[1][1] != "7" || caller.calls[1][3] != "fbrell_examples:*" {
+		t.Fatalf("Did not find expected SCAN calls, found %v", caller.calls)
+	}
+}
+
+func TestDeleteAndExpire(t *testing.T) {
+	t.Parallel()
+	caller := &fakeCaller{}
+	s := &Store{Client: caller}
+	if err := s.Delete("a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Expire("b", 2*time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	expected := [][]interface{}{{"DEL", "a"}, {"EXPIRE", "b", "7200"}}
+	if !reflect.DeepEqual(caller.calls, expected) {
+		t.Fatalf("Did not find expected calls %v instead found %v", expected, caller.calls)
+	}
+}
+
+func TestEscapeGlob(t *testing.T) {
+	t.Parallel()
+	if e := escapeGlob(`a*b?[c]\`); e != `a\*b\?\[c\]\\` {
+		t.Fatalf("Did not find expected escaped glob, found %s", e)
+	}
+}
@@ -0,0 +1,191 @@
+package examples
+
+import (
+	"container/list"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// Matches in the title, category or name count more than matches in
+// the content.
+const titleWeight = 10
+
+// A search result.
+type SearchResult struct {
+	Example  *Example
+	Category string
+	Hidden   bool
+	Score    int
+}
+
+type indexDoc struct {
+	example  *Example
+	category string
+	hidden   bool
+	tokens   map[string]int
+	element  *list.Element // In Index.order, holding the URL.
+}
+
+// An in-process full text index of Examples keyed by their URL. The
+// zero value is ready to use.
+type Index struct {
+	mutex    sync.RWMutex
+	docs     map[string]*indexDoc
+	postings map[string]map[string]int
+	order    *list.List // URLs from least to most recently added.
+}
+
+// Add an Example to the index, replacing any existing entry for the
+// same URL.
+func (i *Index) Add(category string, hidden bool, e *Example) {
+	i.add(category, hidden, e, 0)
+}
+
+// Add an Example to the index, evicting the least recently added
+// Examples to keep at most limit of them. A zero limit means no limit.
+func (i *Index) add(category string, hidden bool, e *Example, limit int) {
+	doc := &indexDoc{
+		example:  e,
+		category: category,
+		hidden:   hidden,
+		tokens:   make(map[string]int),
+	}
+	fields := []string{e.Title, e.Name, category, e.Description}
+	fields = append(fields, e.Tags...)
+	for _, field := range fields {
+		for _, token := range tokenize(field) {
+			doc.tokens[token] += titleWeight
+		}
+	}
+	for _, token := range tokenize(string(e.Content)) {
+		doc.tokens[token]++
+	}
+
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	if i.docs == nil {
+		i.docs = make(map[string]*indexDoc)
+		i.postings = make(map[string]map[string]int)
+		i.order = list.New()
+	}
+	i.remove(e.URL)
+	doc.element = i.order.PushBack(e.URL)
+	i.docs[e.URL] = doc
+	for token, count := range doc.tokens {
+		if i.postings[token] == nil {
+			i.postings[token] = make(map[string]int)
+		}
+		i.postings[token][e.URL] = count
+	}
+	for limit > 0 && len(i.docs) > limit {
+		i.remove(i.order.Front().Value.(string))
+	}
+}
+
+// Remove the Example with the given URL from the index.
+func (i *Index) Remove(url string) {
+	i.mutex.Lock()
+	defer i.mutex.Unlock()
+	i.remove(url)
+}
+
+// Returns the number of Examples in the index.
+func (i *Index) Len() int {
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	return len(i.docs)
+}
+
+func (i *Index) remove(url string) {
+	doc, ok := i.docs[url]
+	if !ok {
+		return
+	}
+	for token := range doc.tokens {
+		delete(i.postings[token], url)
+		if len(i.postings[token]) == 0 {
+			delete(i.postings, token)
+		}
+	}
+	i.order.Remove(doc.element)
+	delete(i.docs, url)
+}
+
+// Search for Examples matching all the terms in the query. Hidden
+// Examples are only included if requested.
+func (i *Index) Search(query string, includeHidden bool) []*SearchResult {
+	terms := tokenize(query)
+	if len(terms) == 0 {
+		return nil
+	}
+
+	i.mutex.RLock()
+	defer i.mutex.RUnlock()
+	scores := make(map[string]int)
+	for n, term := range terms {
+		next := make(map[string]int)
+		for url, count := range i.postings[term] {
+			if _, ok := scores[url]; n == 0 || ok {
+				next[url] = scores[url] + count
+			}
+		}
+		scores = next
+	}
+
+	results := make([]*SearchResult, 0, len(scores))
+	for url, score := range scores {
+		doc := i.docs[url]
+		if doc.hidden && !includeHidden {
+			continue
+		}
+		results = append(results, &SearchResult{
+			Example:  doc.example,
+			Category: doc.category,
+			Hidden:   doc.hidden,
+			Score:    score,
+		})
+	}
+	sort.Sort(byScore(results))
+	return results
+}
+
+type byScore []*SearchResult
+
+func (s byScore) Len() int      { return len(s) }
+func (s byScore) Swap(i, j int) { s[i], s[j] = s[j], s[i] }
+func (s byScore) Less(i, j int) bool {
+	if s[i].Score != s[j].Score {
+		return s[i].Score > s[j].Score
+	}
+	return s[i].Example.URL < s[j].Example.URL
+}
+
+// Characters that are part of a token in addition to letters and
+// digits. This keeps things like FB.api and fb:login-button intact.
+func isTokenPunct(r rune) bool {
+	return r == '.' || r == ':' || r == '-' || r == '_'
+}
+
+// Splits text into lower case tokens. Compound tokens like FB.api are
+// included along with their parts.
+func tokenize(text string) []string {
+	var tokens []string
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && !isTokenPunct(r)
+	})
+	for _, field := range fields {
+		field = strings.TrimFunc(field, isTokenPunct)
+		if field == "" {
+			continue
+		}
+		tokens = append(tokens, field)
+		if strings.IndexFunc(field, isTokenPunct) != -1 {
+			for _, part := range strings.FieldsFunc(field, isTokenPunct) {
+				tokens = append(tokens, part)
+			}
+		}
+	}
+	return tokens
+}
@@ -0,0 +1,105 @@
+package examples
+
+import (
+	"testing"
+)
+
+func testIndex() *Index {
+	index := &Index{}
+	index.Add("fb.ui", false, &Example{
+		Name:    "apprequests",
+		Title:   "fb.ui · apprequests",
+		URL:     "/fb.ui/apprequests",
+		Content: []byte("<script>FB.ui({ method: 'apprequests' })</script>"),
+	})
+	index.Add("auth", false, &Example{
+		Name:    "login-button",
+		Title:   "auth · login-button",
+		URL:     "/auth/login-button",
+		Content: []byte("<fb:login-button></fb:login-button>"),
+	})
+	index.Add("hidden", true, &Example{
+		Name:    "secret",
+		Title:   "hidden · secret",
+		URL:     "/hidden/secret",
+		Content: []byte("<script>FB.ui({ method: 'apprequests' })</script>"),
+	})
+	return index
+}
+
+func TestSearchContentToken(t *testing.T) {
+	t.Parallel()
+	results := testIndex().Search("FB.ui apprequests", false)
+	if len(results) != 1 || results[0].Example.URL != "/fb.ui/apprequests" {
+		t.Fatalf("Did not find expected single result instead found %+v", results)
+	}
+}
+
+func TestSearchCompoundToken(t *testing.T) {
+	t.Parallel()
+	results := testIndex().Search("fb:login-button", false)
+	if len(results) != 1 || results[0].Example.URL != "/auth/login-button" {
+		t.Fatalf("Did not find expected single result instead found %+v", results)
+	}
+}
+
+func TestSearchHidden(t *testing.T) {
+	t.Parallel()
+	results := testIndex().Search("apprequests", true)
+	if len(results) != 2 {
+		t.Fatalf("Was expecting 2 results instead found %+v", results)
+	}
+	if results[0].Example.URL != "/fb.ui/apprequests" {
+		t.Fatalf("Was expecting title match first instead found %+v", results[0])
+	}
+}
+
+func TestSearchReplace(t *testing.T) {
+	t.Parallel()
+	index := testIndex()
+	index.Add("auth", false, &Example{
+		Name:    "login-button",
+		URL:     "/auth/login-button",
+		Content: []byte("nothing to see"),
+	})
+	if results := index.Search("fb:login-button", false); len(results) != 0 {
+		t.Fatalf("Was expecting no results instead found %+v", results)
+	}
+}
+
+func TestIndexLimit(t *testing.T) {
+	t.Parallel()
+	index := &Index{}
+	add := func(url, content string) {
+		index.add("saved", true, &Example{URL: url, Content: []byte(content)}, 2)
+	}
+	add("/saved/a", "alpha")
+	add("/saved/b", "beta")
+	add("/saved/a", "alpha")
+	add("/saved/c", "gamma")
+	if index.Len() != 2 {
+		t.Fatalf("Did not find expected 2 examples, found %d", index.Len())
+	}
+	if results := index.Search("beta", true); len(results) != 0 {
+		t.Fatalf("Was expecting the least recently added to be evicted, found %+v", results)
+	}
+	for _, query := range []string{"alpha", "gamma"} {
+		if results := index.Search(query, true); len(results) != 1 {
+			t.Fatalf("Did not find expected result for %s, found %+v", query, results)
+		}
+	}
+	if len(index.postings) != 3 || index.postings["beta"] != nil {
+		t.Fatalf("Was expecting evicted postings to be removed, found %v", index.postings)
+	}
+}
+
+func TestStoreSavedIndexBounded(t *testing.T) {
+	t.Parallel()
+	s := &Store{}
+	for n := 0; n < maxSavedIndex+10; n++ {
+		s.indexSaved(ContentID([]byte{byte(n), byte(n >> 8)}), []byte("saved"))
+	}
+	if s.saved.Len() != maxSavedIndex {
+		t.Fatalf("Did not find expected %d saved examples, found %d", maxSavedIndex, s.saved.Len())
+	}
+}
@@ -12,7 +12,11 @@ import (
 	"log"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/daaku/go.browserid"
 	"github.com/daaku/go.counting"
 	"github.com/daaku/go.errcode"
 	"github.com/daaku/go.fburl"
@@ -27,14 +31,19 @@ import (
 	"github.com/daaku/sortutil"
 
 	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/context/viewcontext"
 	"github.com/daaku/rell/examples"
+	"github.com/daaku/rell/examples/linediff"
 	"github.com/daaku/rell/js"
+	"github.com/daaku/rell/ratelimit"
 	"github.com/daaku/rell/view"
 )
 
 const (
-	savedPath = "/saved/"
-	paramName = "-xsrf-token-"
+	savedPath     = "/saved/"
+	historySuffix = "/history"
+	apiPath       = "/api/examples"
+	paramName     = "-xsrf-token-"
 )
 
 var (
@@ -54,6 +63,8 @@ var (
 		context.Canvas:  "Canvas",
 	}
 	errTokenMismatch = errcode.New(http.StatusForbidden, "Token mismatch.")
+	errTooManySaves  = errcode.New(
+		ratelimit.StatusTooManyRequests, "Too many saved examples, try again later.")
 )
 
 type Handler struct {
@@ -62,6 +73,8 @@ type Handler struct {
 	Static        *static.Handler
 	Stats         stats.Backend
 	Xsrf          *xsrf.Provider
+	BrowserID     *browserid.Cookie
+	SaveLimiter   *ratelimit.Limiter
 }
 
 // Parse the Context and an Example.
@@ -91,6 +104,158 @@ func (a *Handler) List(w http.ResponseWriter, r *http.Request) {
 	})
 }
 
+type apiExample struct {
+	Category    string   `json:"category"`
+	Name        string   `json:"name"`
+	Title       string   `json:"title"`
+	Description string   `json:"description,omitempty"`
+	URL         string   `json:"url"`
+	Hidden      bool     `json:"hidden"`
+	AutoRun     bool     `json:"autoRun"`
+	Scope       []string `json:"scope,omitempty"`
+	Versions    []string `json:"versions,omitempty"`
+	Tags        []string `json:"tags,omitempty"`
+	Content     string   `json:"content,omitempty"`
+}
+
+type apiCategory struct {
+	Name    string        `json:"name"`
+	Hidden  bool          `json:"hidden"`
+	Example []*apiExample `json:"examples"`
+}
+
+// Handles /api/examples and /api/examples/<category>/<name> requests.
+func (a *Handler) API(w http.ResponseWriter, r *http.Request) {
+	c, err := a.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	rest := strings.Trim(strings.TrimPrefix(r.URL.Path, apiPath), "/")
+	if rest == "search" {
+		query := r.FormValue("q")
+		results := a.ExampleStore.Search(c.Version, query, c.IsEmployee)
+		a.Stats.Count("searched examples api", 1)
+		out := make([]*apiExample, 0, len(results))
+		for _, result := range results {
+			out = append(out, newSearchAPIExample(c, result))
+		}
+		view.JSON(w, r, a.Static, out)
+		return
+	}
+	if rest == "" {
+		a.Stats.Count("viewed examples api listing", 1)
+		catalog := make(map[string][]*apiCategory)
+		for _, version := range examples.Versions {
+			catalog[version] = apiCatalog(c, version)
+		}
+		view.JSON(w, r, a.Static, catalog)
+		return
+	}
+	parts := strings.Split(rest, "/")
+	if len(parts) != 2 {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusNotFound, "Invalid URL: %s", r.URL.Path))
+		return
+	}
+	example := examples.FindExample(c.Version, parts[0], parts[1])
+	if example == nil {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusNotFound, "Could not find example: %s", rest))
+		return
+	}
+	a.Stats.Count("viewed examples api example", 1)
+	result := newAPIExample(c, &examples.Category{
+		Name:   parts[0],
+		Hidden: examples.IsHidden(parts[0]),
+	}, example)
+	result.Content = string(example.Content)
+	view.JSON(w, r, a.Static, result)
+}
+
+// Builds the API representation of all categories for a SDK version.
+func apiCatalog(c *context.Context, version string) []*apiCategory {
+	c = c.Copy()
+	c.Version = version
+	db := examples.GetDB(version)
+	categories := make([]*apiCategory, 0, len(db.Category))
+	for _, category := range db.Category {
+		result := &apiCategory{
+			Name:    category.Name,
+			Hidden:  category.Hidden,
+			Example: make([]*apiExample, 0, len(category.Example)),
+		}
+		for _, example := range category.Example {
+			result.Example = append(
+				result.Example, newAPIExample(c, category, example))
+		}
+		categories = append(categories, result)
+	}
+	return categories
+}
+
+func newSearchAPIExample(c *context.Context, result *examples.SearchResult) *apiExample {
+	return &apiExample{
+		Category:    result.Category,
+		Name:        result.Example.Name,
+		Title:       result.Example.Title,
+		Description: result.Example.Description,
+		URL:         c.AbsoluteURL(result.Example.URL).String(),
+		Hidden:      result.Hidden,
+		AutoRun:     result.Example.AutoRun,
+		Scope:       result.Example.Scope,
+		Versions:    result.Example.Versions,
+		Tags:        result.Example.Tags,
+	}
+}
+
+func newAPIExample(c *context.Context, category *examples.Category, example *examples.Example) *apiExample {
+	return &apiExample{
+		Category:    category.Name,
+		Name:        example.Name,
+		Title:       example.Title,
+		Description: example.Description,
+		URL:         c.AbsoluteURL(example.URL).String(),
+		Hidden:      category.Hidden,
+		AutoRun:     example.AutoRun,
+		Scope:       example.Scope,
+		Versions:    example.Versions,
+		Tags:        example.Tags,
+	}
+}
+
+// Handles /examples/compat requests.
+func (a *Handler) Compat(w http.ResponseWriter, r *http.Request) {
+	context, err := a.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	a.Stats.Count("viewed examples compat", 1)
+	h.WriteResponse(w, r, &compatPage{
+		Context: context,
+		Static:  a.Static,
+		Compat:  examples.Compatibility(),
+	})
+}
+
+// Handles /examples/search requests.
+func (a *Handler) Search(w http.ResponseWriter, r *http.Request) {
+	context, err := a.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	query := r.FormValue("q")
+	a.Stats.Count("searched examples", 1)
+	h.WriteResponse(w, r, &searchPage{
+		Context: context,
+		Static:  a.Static,
+		Query:   query,
+		Results: a.ExampleStore.Search(context.Version, query, context.IsEmployee),
+	})
+}
+
 func (a *Handler) Saved(w http.ResponseWriter, r *http.Request) {
 	if r.Method == "POST" && r.URL.Path == savedPath {
 		c, err := a.ContextParser.FromRequest(r)
@@ -112,14 +277,34 @@ func (a *Handler) Saved(w http.ResponseWriter, r *http.Request) {
 			http.Redirect(w, r, c.ViewURL(example.URL), 302)
 			return
 		}
+		if !a.allowSave(w, r) {
+			view.Error(w, r, a.Static, errTooManySaves)
+			return
+		}
 		err = a.ExampleStore.Save(id, content)
 		if err != nil {
 			view.Error(w, r, a.Static, err)
 			return
 		}
+		rev := &examples.Revision{
+			ID:      id,
+			Created: time.Now(),
+			Values:  c.Values(),
+		}
+		if parent := r.FormValue("parent"); parent != id && examples.IsContentID(parent) {
+			rev.Parent = parent
+		}
+		err = a.ExampleStore.SaveRevision(rev)
+		if err != nil {
+			view.Error(w, r, a.Static, err)
+			return
+		}
 		a.Stats.Count("saved example", 1)
 		http.Redirect(w, r, c.ViewURL(savedPath+id), 302)
 		return
+	} else if strings.HasSuffix(r.URL.Path, historySuffix) {
+		a.History(w, r)
+		return
 	} else {
 		context, example, err := a.parse(r)
 		if err != nil {
@@ -139,6 +324,148 @@ func (a *Handler) Saved(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// Check the save quota for both the browser and the client IP. A
+// denied save does not use up either quota.
+func (a *Handler) allowSave(w http.ResponseWriter, r *http.Request) bool {
+	browser := "browser:" + a.BrowserID.Get(w, r)
+	switch a.SaveLimiter.Check(browser, "ip:"+ratelimit.ClientIP(r)) {
+	case "":
+		return true
+	case browser:
+		a.Stats.Count(savedPath+" throttled by browser", 1)
+	default:
+		a.Stats.Count(savedPath+" throttled by ip", 1)
+	}
+	return false
+}
+
+// Handles /saved/<id>/history requests.
+func (a *Handler) History(w http.ResponseWriter, r *http.Request) {
+	context, err := a.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	id := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, savedPath), historySuffix)
+	if !examples.IsContentID(id) {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusNotFound, "Invalid URL: %s", r.URL.Path))
+		return
+	}
+	history, err := a.ExampleStore.History(id)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	content := make([][]byte, len(history))
+	for i, rev := range history {
+		content[i], err = a.ExampleStore.Content(rev.ID)
+		if err != nil {
+			view.Error(w, r, a.Static, err)
+			return
+		}
+	}
+	if content[0] == nil {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusNotFound, "Example not found: %s", id))
+		return
+	}
+	a.Stats.Count("viewed saved example history", 1)
+	h.WriteResponse(w, r, &historyPage{
+		Context: context,
+		Static:  a.Static,
+		History: history,
+		Content: content,
+	})
+}
+
+// Handles /examples/reload requests on the admin port.
+func (a *Handler) Reload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusMethodNotAllowed, "Reload requires a POST request."))
+		return
+	}
+	if err := examples.Reload(); err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	a.Stats.Count("reloaded examples", 1)
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "Reloaded examples.")
+}
+
+// Handles /examples/store requests on the admin port.
+func (a *Handler) StoreStats(w http.ResponseWriter, r *http.Request) {
+	stats, err := a.ExampleStore.Stats()
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	view.JSON(w, r, a.Static, stats)
+}
+
+// Handles /examples/prune?days=N requests on the admin port.
+func (a *Handler) Prune(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusMethodNotAllowed, "Prune requires a POST request."))
+		return
+	}
+	days, err := strconv.Atoi(r.FormValue("days"))
+	if err != nil || days < 1 {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusBadRequest, "Invalid days: %s", r.FormValue("days")))
+		return
+	}
+	pruned, err := a.ExampleStore.Prune(time.Duration(days) * 24 * time.Hour)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	a.Stats.Count("pruned saved examples", pruned)
+	view.JSON(w, r, a.Static, map[string]int{"pruned": pruned})
+}
+
+// Handles /examples/export requests on the admin port. Specific
+// examples can be selected using one or more id parameters.
+func (a *Handler) Export(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	var bundle bytes.Buffer
+	exported, err := a.ExampleStore.Export(&bundle, r.Form["id"])
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	a.Stats.Count("exported saved examples", exported)
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.Header().Set(
+		"Content-Disposition", `attachment; filename="rell-examples.jsonl"`)
+	bundle.WriteTo(w)
+}
+
+// Handles /examples/import requests on the admin port. The request body
+// should be a bundle created by Export.
+func (a *Handler) Import(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusMethodNotAllowed, "Import requires a POST request."))
+		return
+	}
+	imported, err := a.ExampleStore.Import(r.Body)
+	a.Stats.Count("imported saved examples", imported)
+	if err != nil {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusBadRequest, "Imported %d examples before failing: %s",
+			imported, err))
+		return
+	}
+	view.JSON(w, r, a.Static, map[string]int{"imported": imported})
+}
+
 func (a *Handler) Raw(w http.ResponseWriter, r *http.Request) {
 	context, example, err := a.parse(r)
 	if err != nil {
@@ -264,6 +591,9 @@ func (p *page) HTML() (h.HTML, error) {
 					Inner: &h.Frag{
 						h.HiddenInputs(url.Values{
 							paramName: []string{p.Xsrf.Token(p.Writer, p.Request, savedPath)},
+							viewcontext.XsrfParam: []string{
+								p.Xsrf.Token(p.Writer, p.Request, viewcontext.XsrfAction)},
+							"parent": []string{savedID(p.Example)},
 						}),
 						&h.Div{
 							Class: "row-fluid",
@@ -272,6 +602,7 @@ func (p *page) HTML() (h.HTML, error) {
 									Class: "span8",
 									Inner: &h.Frag{
 										&editorTop{Context: p.Context, Example: p.Example},
+										&exampleInfo{Example: p.Example},
 										&editorArea{
 											ContextParser: p.ContextParser,
 											Context:       p.Context,
@@ -368,6 +699,31 @@ func (e *editorTop) HTML() (h.HTML, error) {
 	}, nil
 }
 
+type exampleInfo struct {
+	Example *examples.Example
+}
+
+func (e *exampleInfo) HTML() (h.HTML, error) {
+	if e.Example.Description == "" && len(e.Example.Scope) == 0 {
+		return nil, nil
+	}
+	frag := &h.Frag{}
+	if e.Example.Description != "" {
+		frag.Append(h.String(e.Example.Description))
+	}
+	if len(e.Example.Scope) > 0 {
+		frag.Append(h.String(" "))
+		frag.Append(&h.Span{
+			Class: "muted",
+			Inner: h.String("Requires " + strings.Join(e.Example.Scope, ", ")),
+		})
+	}
+	return &h.Div{
+		Class: "row-fluid example-info",
+		Inner: frag,
+	}, nil
+}
+
 type editorArea struct {
 	ContextParser *context.Parser
 	Context       *context.Context
@@ -477,14 +833,29 @@ func (e *editorBottom) HTML() (h.HTML, error) {
 			"trigger":   "manual",
 		}
 	}
+	var history h.HTML
+	if id := savedID(e.Example); id != "" {
+		history = &h.Frag{
+			h.String(" "),
+			&h.Span{Class: "bar", Inner: h.String("|")},
+			h.String(" "),
+			&h.A{
+				HREF:  e.Context.URL(savedPath + id + historySuffix).String(),
+				Inner: h.String("History"),
+			},
+		}
+	}
 	return &h.Div{
 		Class: "row-fluid form-inline",
 		Inner: &h.Frag{
 			&h.Strong{
 				Class: "span4",
-				Inner: &h.A{
-					HREF:  e.Context.URL("/examples/").String(),
-					Inner: h.String("Examples"),
+				Inner: &h.Frag{
+					&h.A{
+						HREF:  e.Context.URL("/examples/").String(),
+						Inner: h.String("Examples"),
+					},
+					history,
 				},
 			},
 			&h.Div{
@@ -600,6 +971,24 @@ func (e *contextEditor) HTML() (h.HTML, error) {
 							h.String(" Update"),
 						},
 					},
+					h.String(" "),
+					&h.Button{
+						Type:  "submit",
+						Class: "btn rell-prefs",
+						Data: map[string]interface{}{
+							"action": viewcontext.PrefsSavePath,
+						},
+						Inner: h.String("Save as Defaults"),
+					},
+					h.String(" "),
+					&h.Button{
+						Type:  "submit",
+						Class: "btn rell-prefs",
+						Data: map[string]interface{}{
+							"action": viewcontext.PrefsResetPath,
+						},
+						Inner: h.String("Reset to Defaults"),
+					},
 				},
 			},
 		},
@@ -635,6 +1024,11 @@ func (l *examplesList) HTML() (h.HTML, error) {
 					Class: "span12",
 					Inner: &h.Frag{
 						&h.H1{Inner: h.String("Examples")},
+						&searchForm{Context: l.Context},
+						&h.A{
+							HREF:  l.Context.URL("/examples/compat").String(),
+							Inner: h.String("SDK version compatibility"),
+						},
 						categories,
 					},
 				},
@@ -643,6 +1037,180 @@ func (l *examplesList) HTML() (h.HTML, error) {
 	}, nil
 }
 
+var versionNames = map[string]string{
+	context.Mu:  "Mu",
+	context.Mid: "Mid",
+	context.Old: "Old",
+}
+
+type compatPage struct {
+	Context *context.Context
+	Static  *static.Handler
+	Compat  []*examples.Compat
+}
+
+func (p *compatPage) HTML() (h.HTML, error) {
+	header := &h.Frag{&h.Th{Inner: h.String("Example")}}
+	for _, version := range examples.Versions {
+		header.Append(&h.Th{Inner: h.String(versionNames[version])})
+	}
+	rows := &h.Frag{}
+	for _, row := range p.Compat {
+		if row.Hidden && !p.Context.IsEmployee {
+			continue
+		}
+		cells := &h.Frag{
+			&h.Td{Inner: h.String(row.Category + " · " + row.Name)},
+		}
+		for _, version := range examples.Versions {
+			cells.Append(&h.Td{Inner: p.cell(row, version)})
+		}
+		rows.Append(&h.Tr{Inner: cells})
+	}
+	return &view.Page{
+		Context: p.Context,
+		Static:  p.Static,
+		Title:   "Example Compatibility",
+		Class:   "examples",
+		Body: &h.Div{
+			Class: "container",
+			Inner: &h.Div{
+				Class: "row",
+				Inner: &h.Div{
+					Class: "span12",
+					Inner: &h.Frag{
+						&h.H1{
+							Inner: &h.A{
+								HREF:  p.Context.URL("/examples/").String(),
+								Inner: h.String("Examples"),
+							},
+						},
+						&h.Table{
+							Class: "table table-bordered table-striped",
+							Inner: &h.Frag{
+								&h.Thead{Inner: &h.Tr{Inner: header}},
+								&h.Tbody{Inner: rows},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// Renders a link to the example for the version, noting when it comes
+// from the DB for another version.
+func (p *compatPage) cell(row *examples.Compat, version string) h.HTML {
+	example := row.Example[version]
+	if example == nil {
+		return nil
+	}
+	c := p.Context.Copy()
+	c.Version = version
+	link := &h.A{
+		HREF:  c.URL(example.URL).String(),
+		Inner: &h.I{Class: "icon-ok"},
+	}
+	if example.Version != version {
+		return &h.Frag{
+			link,
+			h.String(" "),
+			&h.Span{
+				Class: "muted",
+				Inner: h.String("via " + versionNames[example.Version]),
+			},
+		}
+	}
+	return link
+}
+
+type searchForm struct {
+	Context *context.Context
+	Query   string
+}
+
+func (f *searchForm) HTML() (h.HTML, error) {
+	return &h.Form{
+		Action: "/examples/search",
+		Method: "get",
+		Class:  "form-search",
+		Inner: &h.Frag{
+			h.HiddenInputs(f.Context.Values()),
+			&h.Input{
+				Type:  "text",
+				Name:  "q",
+				Value: f.Query,
+				Class: "input-xlarge search-query",
+			},
+			h.String(" "),
+			&h.Button{
+				Type:  "submit",
+				Class: "btn",
+				Inner: &h.Frag{
+					&h.I{Class: "icon-search"},
+					h.String(" Search"),
+				},
+			},
+		},
+	}, nil
+}
+
+type searchPage struct {
+	Context *context.Context
+	Static  *static.Handler
+	Query   string
+	Results []*examples.SearchResult
+}
+
+func (p *searchPage) HTML() (h.HTML, error) {
+	var results h.HTML
+	if len(p.Results) == 0 {
+		if p.Query != "" {
+			results = &h.Div{
+				Class: "alert",
+				Inner: h.String("No examples found."),
+			}
+		}
+	} else {
+		li := &h.Frag{}
+		for _, result := range p.Results {
+			li.Append(&h.Li{
+				Inner: &h.A{
+					HREF:  p.Context.URL(result.Example.URL).String(),
+					Inner: h.String(result.Example.Title),
+				},
+			})
+		}
+		results = &h.Ul{Inner: li}
+	}
+	return &view.Page{
+		Context: p.Context,
+		Static:  p.Static,
+		Title:   "Search Examples",
+		Class:   "examples",
+		Body: &h.Div{
+			Class: "container",
+			Inner: &h.Div{
+				Class: "row",
+				Inner: &h.Div{
+					Class: "span12",
+					Inner: &h.Frag{
+						&h.H1{
+							Inner: &h.A{
+								HREF:  p.Context.URL("/examples/").String(),
+								Inner: h.String("Examples"),
+							},
+						},
+						&searchForm{Context: p.Context, Query: p.Query},
+						results,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
 type exampleCategory struct {
 	Context  *context.Context
 	Category *examples.Category
@@ -651,12 +1219,24 @@ type exampleCategory struct {
 func (c *exampleCategory) HTML() (h.HTML, error) {
 	li := &h.Frag{}
 	for _, example := range c.Category.Example {
-		li.Append(&h.Li{
-			Inner: &h.A{
+		item := &h.Frag{
+			&h.A{
 				HREF:  c.Context.URL(example.URL).String(),
 				Inner: h.String(example.Name),
 			},
-		})
+		}
+		if example.Description != "" {
+			item.Append(h.String(" "))
+			item.Append(&h.Span{
+				Class: "muted",
+				Inner: h.String(example.Description),
+			})
+		}
+		for _, tag := range example.Tags {
+			item.Append(h.String(" "))
+			item.Append(&h.Span{Class: "label", Inner: h.String(tag)})
+		}
+		li.Append(&h.Li{Inner: item})
 	}
 	return &h.Frag{
 		&h.H2{Inner: h.String(c.Category.Name)},
@@ -725,6 +1305,94 @@ func (e *envSelector) HTML() (h.HTML, error) {
 	}, nil
 }
 
+type historyPage struct {
+	Context *context.Context
+	Static  *static.Handler
+	History []*examples.Revision
+	Content [][]byte
+}
+
+func (p *historyPage) HTML() (h.HTML, error) {
+	revisions := &h.Frag{}
+	for i, rev := range p.History {
+		u := p.Context.URL(savedPath + rev.ID)
+		if len(rev.Values) > 0 {
+			u.RawQuery = rev.Values.Encode()
+		}
+		title := &h.Frag{
+			&h.A{HREF: u.String(), Inner: h.String(rev.ID)},
+		}
+		if !rev.Created.IsZero() {
+			title.Append(h.String(" saved " + rev.Created.UTC().Format(time.RFC1123)))
+		}
+		var parent []byte
+		if i+1 < len(p.History) {
+			parent = p.Content[i+1]
+		}
+		revisions.Append(&h.Frag{
+			&h.H2{Inner: title},
+			&diffTable{Lines: linediff.Diff(string(parent), string(p.Content[i]))},
+		})
+	}
+	return &view.Page{
+		Context: p.Context,
+		Static:  p.Static,
+		Title:   "History",
+		Class:   "history",
+		Body: &h.Div{
+			Class: "container",
+			Inner: &h.Div{
+				Class: "row",
+				Inner: &h.Div{
+					Class: "span12",
+					Inner: &h.Frag{
+						&h.H1{Inner: h.String("History")},
+						revisions,
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+type diffTable struct {
+	Lines []linediff.Line
+}
+
+func (d *diffTable) HTML() (h.HTML, error) {
+	rows := &h.Frag{}
+	for _, line := range d.Lines {
+		var class, marker string
+		switch line.Op {
+		case linediff.Insert:
+			class, marker = "diff-insert", "+"
+		case linediff.Delete:
+			class, marker = "diff-delete", "-"
+		default:
+			marker = " "
+		}
+		rows.Append(&h.Tr{
+			Class: class,
+			Inner: &h.Frag{
+				&h.Td{Class: "diff-marker", Inner: h.String(marker)},
+				&h.Td{Inner: h.String(line.Text)},
+			},
+		})
+	}
+	return &h.Table{
+		Class: "table table-condensed diff",
+		Inner: &h.Tbody{Inner: rows},
+	}, nil
+}
+
+// Returns the saved example ID if the example is a saved one.
+func savedID(e *examples.Example) string {
+	if !strings.HasPrefix(e.URL, savedPath) {
+		return ""
+	}
+	return e.URL[len(savedPath):]
+}
+
 type exampleContent struct {
 	ContextParser *context.Parser
 	Context       *context.Context
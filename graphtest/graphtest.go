@@ -0,0 +1,34 @@
+// Package graphtest provides a fake Graph API server for tests.
+package graphtest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+)
+
+// Sends all requests to the fake Graph server regardless of the host.
+type Transport struct {
+	Server *httptest.Server
+}
+
+// Start a fake Graph server using the given handler. The caller should call
+// Close when done.
+func New(handler http.Handler) *Transport {
+	return &Transport{Server: httptest.NewServer(handler)}
+}
+
+func (t *Transport) RoundTrip(r *http.Request) (*http.Response, error) {
+	u, err := url.Parse(t.Server.URL)
+	if err != nil {
+		return nil, err
+	}
+	r.URL.Scheme = u.Scheme
+	r.URL.Host = u.Host
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// Shutdown the fake Graph server.
+func (t *Transport) Close() {
+	t.Server.Close()
+}
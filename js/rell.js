@@ -36,6 +36,7 @@ var Rell = {
   init: function(config, example) {
     Rell.config = config
     Rell.config.autoRun = example ? example.autoRun : false
+    Rell.config.scope = example && example.scope ? example.scope.join(',') : ''
     Log.init($('#log')[0], Rell.config.level)
     Log.debug('Configuration', Rell.config);
     (Rell['init_' + Rell.config.version] || Rell.init_old)()
@@ -44,6 +45,7 @@ var Rell = {
     $('#rell-logout').click(Rell.logout)
     $('#rell-run-code').click(Rell.runCode)
     $('#rell-log-clear').click(Rell.clearLog)
+    $('.rell-prefs').click(Rell.submitPrefs)
     Rell.setCurrentViewMode()
     if (example && !example.autoRun) {
       Rell.setupAutoRunPopover()
@@ -163,13 +165,21 @@ var Rell = {
     }
   },
 
+  /**
+   * Submits the context editor to the preferences handler instead of saving
+   * the example.
+   */
+  submitPrefs: function() {
+    this.form.action = $(this).data('action')
+  },
+
   getCode: function() {
     return $('#jscode').val()
   },
 
   login: function() {
     if (Rell.config.version == 'mu') {
-      FB.login(Log.debug.bind('FB.login callback'))
+      FB.login(Log.debug.bind('FB.login callback'), { scope: Rell.config.scope })
     } else {
       FB.Connect.requireSession(Log.debug.bind('requireSession callback'))
     }
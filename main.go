@@ -3,6 +3,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"log"
 	"net/http"
 	"os"
@@ -17,7 +18,6 @@ import (
 	"github.com/daaku/go.httpcontrol"
 	"github.com/daaku/go.redis"
 	"github.com/daaku/go.redis/bytecache"
-	"github.com/daaku/go.redis/bytestore"
 	"github.com/daaku/go.static"
 	"github.com/daaku/go.stats/stathat"
 	"github.com/daaku/go.subcache"
@@ -26,18 +26,27 @@ import (
 	"github.com/daaku/rell/collector"
 	"github.com/daaku/rell/context"
 	"github.com/daaku/rell/context/appns"
+	"github.com/daaku/rell/context/apps"
 	"github.com/daaku/rell/context/empcheck"
 	"github.com/daaku/rell/context/viewcontext"
 	"github.com/daaku/rell/examples"
+	"github.com/daaku/rell/examples/diskstore"
+	"github.com/daaku/rell/examples/memstore"
+	"github.com/daaku/rell/examples/redisstore"
 	"github.com/daaku/rell/examples/viewexamples"
 	"github.com/daaku/rell/oauth"
 	"github.com/daaku/rell/og"
 	"github.com/daaku/rell/og/viewog"
+	"github.com/daaku/rell/ratelimit"
+	"github.com/daaku/rell/requestlog"
+	"github.com/daaku/rell/sr/viewsr"
+	"github.com/daaku/rell/token"
 	"github.com/daaku/rell/web"
 )
 
 func main() {
 	mainapp := fbapp.Flag("fbapp")
+	appRegistry := &apps.Registry{Default: mainapp}
 	bid := browserid.CookieFlag("browserid")
 	sh := stathat.ClientFlag("rell.stats")
 	redis := redis.ClientFlag("rell.redis")
@@ -45,7 +54,7 @@ func main() {
 	xsrf.BrowserID = bid
 	static := static.HandlerFlag("rell.static")
 	byteCache := bytecache.New(redis)
-	byteStore := bytestore.New(redis)
+	byteStore := redisstore.New(redis)
 	httpTransport := httpcontrol.TransportFlag("rell.transport")
 	fbApiClient := fbapi.ClientFlag("rell.fbapi")
 	logger := log.New(os.Stderr, "", log.LstdFlags)
@@ -66,7 +75,6 @@ func main() {
 		},
 	}
 	appNSFetcher := &appns.Fetcher{
-		Apps:         []fbapp.App{mainapp},
 		FbApiClient:  fbApiClient,
 		Logger:       logger,
 		CacheTimeout: 60 * 24 * time.Hour,
@@ -77,21 +85,28 @@ func main() {
 			ErrorLogger: logger,
 		},
 	}
-	exampleStore := &examples.Store{ByteStore: byteStore}
+	exampleStore := &examples.Store{}
+	objectStore := &og.Store{}
+	saveLimiter := &ratelimit.Limiter{}
+	requestLog := &requestlog.Handler{Logger: logger}
 	contextParser := &context.Parser{
 		App:          mainapp,
+		Apps:         appRegistry,
 		EmpChecker:   empChecker,
 		AppNSFetcher: appNSFetcher,
 		Stats:        sh,
+		RequestLog:   requestLog,
 	}
 
 	app := &web.App{
-		Stats:  sh,
-		Static: static,
-		App:    mainapp,
+		Stats:      sh,
+		Static:     static,
+		Apps:       appRegistry,
+		RequestLog: requestLog,
 		ContextHandler: &viewcontext.Handler{
 			ContextParser: contextParser,
 			Static:        static,
+			Xsrf:          xsrf,
 		},
 		ExamplesHandler: &viewexamples.Handler{
 			ContextParser: contextParser,
@@ -99,20 +114,34 @@ func main() {
 			Stats:         sh,
 			Xsrf:          xsrf,
 			Static:        static,
+			BrowserID:     bid,
+			SaveLimiter:   saveLimiter,
 		},
 		OgHandler: &viewog.Handler{
 			ContextParser: contextParser,
 			Stats:         sh,
 			Static:        static,
-			ObjectParser:  &og.Parser{Static: static},
+			ObjectParser:  &og.Parser{Static: static, Store: objectStore},
+			SaveLimiter:   saveLimiter,
 		},
 		OauthHandler: &oauth.Handler{
 			BrowserID:     bid,
-			App:           mainapp,
+			Apps:          appRegistry,
+			ContextParser: contextParser,
+			HttpTransport: httpTransport,
+			Static:        static,
+		},
+		TokenHandler: &token.Handler{
+			Apps:          appRegistry,
 			ContextParser: contextParser,
 			HttpTransport: httpTransport,
 			Static:        static,
 		},
+		SrHandler: &viewsr.Handler{
+			Apps:          appRegistry,
+			ContextParser: contextParser,
+			Static:        static,
+		},
 	}
 
 	mainAddress := flag.String(
@@ -125,6 +154,55 @@ func main() {
 		":43601",
 		"Admin http server address.",
 	)
+	exampleStoreBackend := flag.String(
+		"rell.store",
+		"redis",
+		"Backend for saved examples and OG objects, one of redis, memory or disk.",
+	)
+	exampleStoreDir := flag.String(
+		"rell.store.dir",
+		"",
+		"Directory for saved examples when using the disk backend.",
+	)
+	flag.DurationVar(
+		&exampleStore.TTL,
+		"rell.store.ttl",
+		0,
+		"Expire saved examples and OG objects not viewed within this duration, 0 to keep forever.",
+	)
+	examplesReloadInterval := flag.Duration(
+		"rell.examples.reload",
+		0,
+		"Interval to rescan the examples directories for changes, 0 to disable.",
+	)
+	flag.IntVar(
+		&saveLimiter.Count,
+		"rell.save.limit",
+		30,
+		"Maximum saved examples and OG objects per browser and per IP, 0 to disable.",
+	)
+	flag.DurationVar(
+		&saveLimiter.Interval,
+		"rell.save.interval",
+		time.Hour,
+		"Interval for rell.save.limit.",
+	)
+	flag.BoolVar(
+		&app.SrHandler.Generate,
+		"rell.signed-request.generate",
+		false,
+		"Allow generating signed requests and the simulator, for local development only.",
+	)
+	ogDefaultsDir := flag.String(
+		"rell.og.defaults",
+		"",
+		"Directory with images.txt and descriptions.txt pools for OG defaults, optionally per og:type in sub-directories.",
+	)
+	flag.Var(
+		appRegistry,
+		"rell.apps",
+		"Additional apps as id:secret[:namespace], comma separated or repeated.",
+	)
 	goMaxProcs := flag.Int(
 		"rell.gomaxprocs",
 		runtime.NumCPU(),
@@ -139,6 +217,42 @@ func main() {
 	sh.Transport = httpTransport
 	fbApiClient.Transport = httpTransport
 	redis.Stats = sh
+	appNSFetcher.Apps = appRegistry.All()
+
+	switch *exampleStoreBackend {
+	case "redis":
+		exampleStore.ByteStore = byteStore
+	case "memory":
+		exampleStore.ByteStore = &memstore.Store{}
+	case "disk":
+		if *exampleStoreDir == "" {
+			logger.Fatal("rell.store.dir is required for the disk backend")
+		}
+		exampleStore.ByteStore = &diskstore.Store{Dir: *exampleStoreDir}
+	default:
+		logger.Fatalf("unknown rell.store backend: %s", *exampleStoreBackend)
+	}
+	objectStore.ByteStore = exampleStore.ByteStore
+	objectStore.TTL = exampleStore.TTL
+
+	if *ogDefaultsDir != "" {
+		defaults, err := og.LoadDefaults(*ogDefaultsDir, static)
+		if err != nil {
+			logger.Fatal(err)
+		}
+		app.OgHandler.ObjectParser.Defaults = defaults
+	}
+
+	if flag.NArg() > 0 {
+		if err := command(exampleStore, flag.Args()); err != nil {
+			logger.Fatal(err)
+		}
+		return
+	}
+
+	if *examplesReloadInterval > 0 {
+		go examples.Watch(*examplesReloadInterval)
+	}
 
 	if err := sh.Start(); err != nil {
 		logger.Fatal(err)
@@ -162,3 +276,24 @@ func main() {
 		logger.Fatal(err)
 	}
 }
+
+// Runs a subcommand instead of the server:
+//
+//	rell export [id...] > bundle.jsonl
+//	rell import < bundle.jsonl
+func command(store *examples.Store, args []string) error {
+	switch args[0] {
+	case "export":
+		n, err := store.Export(os.Stdout, args[1:])
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(os.Stderr, "Exported %d examples.\n", n)
+		return nil
+	case "import":
+		n, err := store.Import(os.Stdin)
+		fmt.Fprintf(os.Stderr, "Imported %d examples.\n", n)
+		return err
+	}
+	return fmt.Errorf("unknown command: %s", args[0])
+}
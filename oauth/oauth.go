@@ -2,41 +2,59 @@
 package oauth
 
 import (
+	"bytes"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
+	"time"
 
-	"github.com/daaku/go.browserid"
-	"github.com/daaku/go.fbapp"
+	"github.com/daaku/go.errcode"
 	"github.com/daaku/go.fburl"
 	"github.com/daaku/go.h"
 	"github.com/daaku/go.static"
 
 	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/context/apps"
+	"github.com/daaku/rell/requestlog"
 	"github.com/daaku/rell/view"
 )
 
 const (
 	Path = "/oauth/"
 	resp = "response/"
+
+	// The cookie holding the access token from the server side flow.
+	SessionCookie = "fbrell_session"
+
+	// The most we read from a Graph API response.
+	MaxResponseSize = 1 << 20 // 1 MB
 )
 
 var (
-	errOAuthFail    = errors.New("OAuth code exchange failure.")
-	errInvalidState = errors.New("Invalid state")
+	errOAuthFail = errcode.New(
+		http.StatusBadGateway, "OAuth code exchange failure: no access token returned.")
+	errMissingCode = errcode.New(
+		http.StatusBadRequest, "The signed request did not include a code.")
 )
 
+// Provides the browser ID, such as *browserid.Cookie.
+type BrowserID interface {
+	Get(w http.ResponseWriter, r *http.Request) string
+}
+
 type Handler struct {
 	ContextParser *context.Parser
 	HttpTransport http.RoundTripper
 	Static        *static.Handler
-	App           fbapp.App
-	BrowserID     *browserid.Cookie
+	Apps          *apps.Registry
+	BrowserID     BrowserID
+	used          nonceSet
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -63,12 +81,13 @@ func (a *Handler) Start(w http.ResponseWriter, r *http.Request) {
 		values.Set("scope", scope)
 	}
 
-	if c.ViewMode == context.Website {
-		values.Set("redirect_uri", redirectURI(c))
-		values.Set("state", a.state(w, r))
-	} else {
-		values.Set("redirect_uri", c.ViewURL("/auth/session"))
+	state, err := a.state(w, r, returnURL(c, r))
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
 	}
+	values.Set("redirect_uri", redirectURI(c, state))
+	values.Set("state", state)
 
 	dialogURL := fburl.URL{
 		Scheme:    "https",
@@ -77,33 +96,51 @@ func (a *Handler) Start(w http.ResponseWriter, r *http.Request) {
 		Path:      "/dialog/oauth",
 		Values:    values,
 	}
-
-	if c.ViewMode == context.Website {
-		http.Redirect(w, r, dialogURL.String(), 302)
-	} else {
-		b, _ := json.Marshal(dialogURL.String())
-		h.WriteResponse(w, r, &h.Script{
-			Inner: h.Unsafe(fmt.Sprintf("top.location=%s", b)),
-		})
-	}
+	redirect(w, r, c, dialogURL.String())
 }
 
-func (h *Handler) Response(w http.ResponseWriter, r *http.Request) {
-	c, err := h.ContextParser.FromRequest(r)
+func (a *Handler) Response(w http.ResponseWriter, r *http.Request) {
+	c, err := a.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	if desc := r.FormValue("error_description"); desc != "" {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusForbidden, "OAuth dialog failed: %s", desc))
+		return
+	}
+	app, err := a.Apps.Lookup(c.AppID)
 	if err != nil {
-		view.Error(w, r, h.Static, err)
+		view.Error(w, r, a.Static, err)
 		return
 	}
-	if r.FormValue("state") != h.state(w, r) {
-		view.Error(w, r, h.Static, errInvalidState)
+
+	state, err := a.checkState(w, r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
 		return
 	}
+	returnTo := state.Return
+	code := r.FormValue("code")
+	redirectTo := redirectURI(c, r.FormValue("state"))
+	if c.ViewMode == context.PageTab {
+		// Page Tabs drop our query parameters, so the state comes back in the
+		// app_data and the code in the signed request. Such codes are
+		// exchanged with an empty redirect_uri.
+		if c.SignedRequest == nil || c.SignedRequest.Code == "" {
+			view.Error(w, r, a.Static, errMissingCode)
+			return
+		}
+		code = c.SignedRequest.Code
+		redirectTo = ""
+	}
 
 	values := url.Values{}
-	values.Set("client_id", strconv.FormatUint(h.App.ID(), 10))
-	values.Set("client_secret", h.App.Secret())
-	values.Set("redirect_uri", redirectURI(c))
-	values.Set("code", r.FormValue("code"))
+	values.Set("client_id", strconv.FormatUint(app.ID(), 10))
+	values.Set("client_secret", app.Secret())
+	values.Set("redirect_uri", redirectTo)
+	values.Set("code", code)
 
 	atURL := &fburl.URL{
 		Scheme:    "https",
@@ -113,27 +150,229 @@ func (h *Handler) Response(w http.ResponseWriter, r *http.Request) {
 		Values:    values,
 	}
 
-	req, err := http.NewRequest("GET", atURL.String(), nil)
+	t, err := exchange(a.HttpTransport, atURL.String())
 	if err != nil {
-		log.Printf("oauth.Response error: %s", err)
-		view.Error(w, r, h.Static, errOAuthFail)
+		log.Printf("oauth.Response error: %s (request %s)", err, requestlog.ID(r))
+		view.Error(w, r, a.Static, err)
+		return
 	}
-	res, err := h.HttpTransport.RoundTrip(req)
+
+	sealed, err := sealSession(a.Apps.Default.SecretByte(), t.AccessToken)
 	if err != nil {
-		log.Printf("oauth.Response error: %s", err)
-		view.Error(w, r, h.Static, errOAuthFail)
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	cookie := &http.Cookie{
+		Name:     SessionCookie,
+		Value:    sealed,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   c.Scheme == "https",
+	}
+	if t.Expires > 0 {
+		cookie.MaxAge = int(t.Expires)
+	}
+	http.SetCookie(w, cookie)
+
+	if returnTo != "" {
+		if c.ViewMode != context.Website {
+			returnTo = c.ViewURL(returnTo)
+		}
+		redirect(w, r, c, returnTo)
+		return
+	}
+	h.WriteResponse(w, r, &resultPage{
+		Context: c,
+		Static:  a.Static,
+		Token:   t,
+	})
+}
+
+// The parsed response from the access_token endpoint.
+type token struct {
+	AccessToken string
+	Expires     int64 // Seconds, zero if the token does not expire.
+}
+
+// The error payload returned by the Graph API.
+type graphError struct {
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+// Exchange a code for an access token using the given access_token URL.
+func exchange(transport http.RoundTripper, atURL string) (*token, error) {
+	req, err := http.NewRequest("GET", atURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, exchangeError(err)
 	}
 	defer res.Body.Close()
-	if _, err := io.Copy(w, res.Body); err != nil {
-		view.Error(w, r, h.Static, err)
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, MaxResponseSize))
+	if err != nil {
+		return nil, exchangeError(err)
+	}
+	body = bytes.TrimSpace(body)
+
+	if bytes.HasPrefix(body, []byte("{")) {
+		var e graphError
+		if err := json.Unmarshal(body, &e); err == nil && e.Error != nil {
+			return nil, errcode.New(
+				http.StatusBadGateway, "OAuth code exchange failed: %s (%s %d)",
+				e.Error.Message, e.Error.Type, e.Error.Code)
+		}
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, errcode.New(
+			http.StatusBadGateway,
+			"OAuth code exchange failed with status %d.", res.StatusCode)
+	}
+
+	t := &token{}
+	if bytes.HasPrefix(body, []byte("{")) {
+		var v struct {
+			AccessToken string `json:"access_token"`
+			ExpiresIn   int64  `json:"expires_in"`
+			Expires     int64  `json:"expires"`
+		}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return nil, exchangeError(err)
+		}
+		t.AccessToken = v.AccessToken
+		t.Expires = v.ExpiresIn
+		if t.Expires == 0 {
+			t.Expires = v.Expires
+		}
+	} else {
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, exchangeError(err)
+		}
+		t.AccessToken = values.Get("access_token")
+		if expires := values.Get("expires"); expires != "" {
+			t.Expires, _ = strconv.ParseInt(expires, 10, 64)
+		}
+	}
+	if t.AccessToken == "" {
+		return nil, errOAuthFail
+	}
+	return t, nil
+}
+
+// Wrap the underlying cause of a failed exchange.
+func exchangeError(err error) error {
+	return errcode.New(
+		http.StatusBadGateway, "OAuth code exchange failure: %s", err)
+}
+
+type resultPage struct {
+	Context *context.Context
+	Static  *static.Handler
+	Token   *token
+}
+
+func (p *resultPage) HTML() (h.HTML, error) {
+	expires := "never"
+	if p.Token.Expires > 0 {
+		expires = (time.Duration(p.Token.Expires) * time.Second).String()
+	}
+	return &view.Page{
+		Context: p.Context,
+		Static:  p.Static,
+		Title:   "Logged In",
+		Class:   "oauth-result",
+		Body: &h.Div{
+			Class: "container",
+			Inner: &h.Div{
+				Class: "row",
+				Inner: &h.Div{
+					Class: "span12",
+					Inner: &h.Frag{
+						&h.H1{Inner: h.String("Logged In")},
+						&h.Div{
+							Inner: h.String(
+								"The OAuth code was exchanged for an access token which " +
+									"expires in " + expires + "."),
+						},
+						&h.A{
+							HREF:  p.Context.URL("/").String(),
+							Inner: h.String("Back to Rell"),
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// Create a signed state for a new flow that will return to the given URL.
+func (a *Handler) state(w http.ResponseWriter, r *http.Request, returnTo string) (string, error) {
+	s, err := newState(a.BrowserID.Get(w, r), returnTo, time.Now())
+	if err != nil {
+		return "", err
+	}
+	return encodeState(a.Apps.Default.SecretByte(), s)
+}
+
+// Verify the state in the request and mark it as used.
+func (a *Handler) checkState(w http.ResponseWriter, r *http.Request) (*state, error) {
+	now := time.Now()
+	s, err := decodeState(
+		a.Apps.Default.SecretByte(), r.FormValue("state"), a.BrowserID.Get(w, r), now)
+	if err != nil {
+		return nil, err
+	}
+	if !a.used.Use(s, now) {
+		return nil, errReusedState
+	}
+	return s, nil
+}
+
+// The redirect_uri for the dialog keeps the user in the current view mode.
+// Page Tabs only pass along the app_data, so it carries the state.
+func redirectURI(c *context.Context, state string) string {
+	if c.ViewMode == context.PageTab {
+		return c.ViewURL(Path + resp + "?state=" + url.QueryEscape(state))
+	}
+	return c.ViewURL(Path + resp)
+}
+
+// Redirect the top frame, which in Canvas and Page Tab modes must be done
+// from script since we are in an iframe.
+func redirect(w http.ResponseWriter, r *http.Request, c *context.Context, u string) {
+	if c.ViewMode == context.Website {
+		http.Redirect(w, r, u, 302)
 		return
 	}
+	b, _ := json.Marshal(u)
+	h.WriteResponse(w, r, &h.Script{
+		Inner: h.Unsafe(fmt.Sprintf("top.location=%s", b)),
+	})
 }
 
-func (h *Handler) state(w http.ResponseWriter, r *http.Request) string {
-	return h.BrowserID.Get(w, r)[:10]
+// Find the local URL to return to after the flow completes. The explicit
+// "return" parameter wins, otherwise a same host Referer is used.
+func returnURL(c *context.Context, r *http.Request) string {
+	if returnTo := r.FormValue("return"); returnTo != "" {
+		return localURL(returnTo)
+	}
+	if ref, err := url.Parse(r.Referer()); err == nil && ref.Host == c.Host {
+		return localURL(ref.RequestURI())
+	}
+	return ""
 }
 
-func redirectURI(c *context.Context) string {
-	return c.AbsoluteURL(Path + resp).String()
+// Only allow paths on this host to avoid an open redirect.
+func localURL(s string) string {
+	if !strings.HasPrefix(s, "/") || strings.HasPrefix(s, "//") ||
+		strings.HasPrefix(s, "/\\") {
+		return ""
+	}
+	return s
 }
@@ -0,0 +1,325 @@
+package oauth
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daaku/go.errcode"
+	"github.com/daaku/go.fbapp"
+	"github.com/daaku/go.signedrequest/appdata"
+
+	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/context/appns"
+	"github.com/daaku/rell/context/apps"
+	"github.com/daaku/rell/graphtest"
+	"github.com/daaku/rell/sr"
+)
+
+func newFakeGraph(status int, contentType, body string) *graphtest.Transport {
+	return graphtest.New(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/oauth/access_token" {
+				http.NotFound(w, r)
+				return
+			}
+			if r.FormValue("code") != "the-code" {
+				w.WriteHeader(http.StatusBadRequest)
+				fmt.Fprint(w, `{"error":{"message":"Invalid code.","type":"OAuthException","code":100}}`)
+				return
+			}
+			w.Header().Set("Content-Type", contentType)
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+		}))
+}
+
+const atURL = "https://graph.facebook.com/oauth/access_token?code=the-code"
+
+func TestExchangeFormEncoded(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(200, "text/plain", "access_token=abc&expires=3600")
+	defer g.Close()
+	tok, err := exchange(g, atURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "abc" {
+		t.Fatalf("Did not find expected token abc instead found %s", tok.AccessToken)
+	}
+	if tok.Expires != 3600 {
+		t.Fatalf("Did not find expected expires 3600 instead found %d", tok.Expires)
+	}
+}
+
+func TestExchangeJSON(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(
+		200, "application/json", `{"access_token":"abc","expires_in":60}`)
+	defer g.Close()
+	tok, err := exchange(g, atURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tok.AccessToken != "abc" || tok.Expires != 60 {
+		t.Fatalf("Did not find expected token instead found %+v", tok)
+	}
+}
+
+func TestExchangeErrorPayload(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(200, "text/plain", "")
+	defer g.Close()
+	_, err := exchange(g, strings.Replace(atURL, "the-code", "bad", 1))
+	if err == nil {
+		t.Fatal("Was expecting an error.")
+	}
+	if !strings.Contains(err.Error(), "Invalid code.") {
+		t.Fatalf("Did not find expected message instead found %s", err)
+	}
+	if e, ok := err.(errcode.E); !ok || e.Code() != http.StatusBadGateway {
+		t.Fatalf("Did not find expected code %d for %v", http.StatusBadGateway, err)
+	}
+}
+
+func TestExchangeBadStatus(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(500, "text/plain", "access_token=abc")
+	defer g.Close()
+	if _, err := exchange(g, atURL); err == nil {
+		t.Fatal("Was expecting an error.")
+	}
+}
+
+func TestExchangeMissingToken(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(200, "text/plain", "expires=10")
+	defer g.Close()
+	if _, err := exchange(g, atURL); err != errOAuthFail {
+		t.Fatalf("Did not find expected errOAuthFail instead found %v", err)
+	}
+}
+
+type failTransport struct{}
+
+func (failTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestExchangeTransportError(t *testing.T) {
+	t.Parallel()
+	_, err := exchange(failTransport{}, atURL)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("Did not find expected underlying error instead found %v", err)
+	}
+	if e, ok := err.(errcode.E); !ok || e.Code() != http.StatusBadGateway {
+		t.Fatalf("Did not find expected code %d for %v", http.StatusBadGateway, err)
+	}
+}
+
+func TestLocalURL(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		in       string
+		expected string
+	}{
+		{"/examples/", "/examples/"},
+		{"/saved/abc?appid=1", "/saved/abc?appid=1"},
+		{"//evil.com/", ""},
+		{"/\\evil.com/", ""},
+		{"http://evil.com/", ""},
+		{"", ""},
+	}
+	for _, c := range cases {
+		if actual := localURL(c.in); actual != c.expected {
+			t.Fatalf("Did not find expected %q for %q instead found %q",
+				c.expected, c.in, actual)
+		}
+	}
+}
+
+type fakeBrowserID string
+
+func (f fakeBrowserID) Get(w http.ResponseWriter, r *http.Request) string {
+	return string(f)
+}
+
+func newTestHandler(transport http.RoundTripper) *Handler {
+	app := fbapp.New(184484190795, "the-secret", "fbrell")
+	return &Handler{
+		ContextParser: &context.Parser{
+			App:          app,
+			Apps:         &apps.Registry{Default: app},
+			AppNSFetcher: &appns.Fetcher{Apps: []fbapp.App{app}},
+		},
+		HttpTransport: transport,
+		Apps:          &apps.Registry{Default: app},
+		BrowserID:     fakeBrowserID("the-browser"),
+	}
+}
+
+// Serve a request with an empty multipart body, which the context parser
+// accepts along with the query parameters in the URL.
+func serve(handler http.Handler, u string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.Close()
+	r, _ := http.NewRequest("POST", u, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, r)
+	return w
+}
+
+// The URL a response redirects to, either with a Location header or from
+// script in Canvas and Page Tab views.
+func redirectLocation(t *testing.T, w *httptest.ResponseRecorder) *url.URL {
+	location := w.Header().Get("Location")
+	if location == "" {
+		body := w.Body.String()
+		start := strings.Index(body, "top.location=")
+		end := strings.Index(body, "</script>")
+		if start == -1 || end < start {
+			t.Fatalf("Did not find expected redirect, found %d %s", w.Code, body)
+		}
+		err := json.Unmarshal([]byte(body[start+len("top.location="):end]), &location)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	u, err := url.Parse(location)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+// Start the flow with the given query and return the dialog URL.
+func startDialog(t *testing.T, handler http.Handler, query string) *url.URL {
+	dialog := redirectLocation(t, serve(handler, "http://www.fbrell.com"+Path+"?"+query))
+	if dialog.Query().Get("state") == "" {
+		t.Fatalf("Did not find expected state in %s", dialog)
+	}
+	return dialog
+}
+
+// Start the flow and return the state sent to the dialog.
+func startState(t *testing.T, handler http.Handler, returnTo string) string {
+	dialog := startDialog(t, handler, "return="+url.QueryEscape(returnTo))
+	return dialog.Query().Get("state")
+}
+
+func TestResponse(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(200, "text/plain", "access_token=abc&expires=3600")
+	defer g.Close()
+	handler := newTestHandler(g)
+
+	state := startState(t, handler, "/examples/?version=mid")
+	response := serve(handler, "http://www.fbrell.com"+Path+resp+
+		"?code=the-code&state="+url.QueryEscape(state))
+	if response.Code != 302 {
+		t.Fatalf("Did not find expected redirect, found %d %s",
+			response.Code, response.Body.String())
+	}
+	if location := response.Header().Get("Location"); location != "/examples/?version=mid" {
+		t.Fatalf("Did not find expected return URL, found %s", location)
+	}
+	r := &http.Request{Header: http.Header{
+		"Cookie": response.HeaderMap["Set-Cookie"],
+	}}
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		t.Fatalf("Did not find expected session cookie in %v", response.HeaderMap)
+	}
+	if cookie.Value == "abc" {
+		t.Fatal("Was expecting the session cookie to be sealed.")
+	}
+	if token := SessionToken([]byte("the-secret"), r); token != "abc" {
+		t.Fatalf("Did not find expected token in the session cookie, found %q", token)
+	}
+	if !strings.Contains(response.Header().Get("Set-Cookie"), "HttpOnly") {
+		t.Fatalf("Was expecting an HttpOnly cookie, found %s", response.Header().Get("Set-Cookie"))
+	}
+
+	replay := serve(handler, "http://www.fbrell.com"+Path+resp+
+		"?code=the-code&state="+url.QueryEscape(state))
+	if replay.Code != http.StatusBadRequest {
+		t.Fatalf("Was expecting the state to be rejected on reuse, found %d", replay.Code)
+	}
+}
+
+func TestResponseCanvasKeepsQuery(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(200, "text/plain", "access_token=abc")
+	defer g.Close()
+	handler := newTestHandler(g)
+	state := startState(t, handler, "/examples/?version=mid")
+	location := redirectLocation(t, serve(handler, "http://www.fbrell.com"+Path+resp+
+		"?view-mode=canvas&code=the-code&state="+url.QueryEscape(state)))
+	if location.Path != "/fbrell/examples" {
+		t.Fatalf("Did not find expected canvas path, found %s", location)
+	}
+	if location.Query().Get("version") != "mid" {
+		t.Fatalf("Did not find expected query in %s", location)
+	}
+}
+
+// A Page Tab signed request from Facebook with the code and app_data.
+func pageTabRequest(t *testing.T, appData string) string {
+	raw, err := sr.Encode(map[string]interface{}{
+		"page":     map[string]interface{}{"id": "1"},
+		"code":     "the-code",
+		"app_data": appData,
+	}, []byte("the-secret"), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return url.QueryEscape(raw)
+}
+
+func TestResponsePageTab(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(200, "text/plain", "access_token=abc")
+	defer g.Close()
+	handler := newTestHandler(g)
+	tab := &appdata.Handler{Handler: handler, Secret: []byte("the-secret")}
+
+	dialog := startDialog(t, handler,
+		"view-mode=page-tab&return="+url.QueryEscape("/examples/?version=mid"))
+	redirectURI, err := url.Parse(dialog.Query().Get("redirect_uri"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	appData := redirectURI.Query().Get("app_data")
+	if appData == "" {
+		t.Fatalf("Did not find expected app_data in %s", redirectURI)
+	}
+
+	forged := serve(tab, "http://www.fbrell.com/?signed_request="+
+		pageTabRequest(t, appdata.Encode(&url.URL{Path: Path + resp})))
+	if forged.Header().Get("Set-Cookie") != "" {
+		t.Fatalf("Was not expecting a session without the state, found %v", forged.HeaderMap)
+	}
+
+	response := serve(tab, "http://www.fbrell.com/?signed_request="+pageTabRequest(t, appData))
+	location := redirectLocation(t, response)
+	expected := appdata.Encode(&url.URL{Path: "/examples/", RawQuery: "version=mid"})
+	if location.Query().Get("app_data") != expected {
+		t.Fatalf("Did not find expected return URL in %s", location)
+	}
+	r := &http.Request{Header: http.Header{
+		"Cookie": response.HeaderMap["Set-Cookie"],
+	}}
+	if token := SessionToken([]byte("the-secret"), r); token != "abc" {
+		t.Fatalf("Did not find expected token in the session cookie, found %q", token)
+	}
+}
@@ -0,0 +1,77 @@
+package oauth
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"net/http"
+)
+
+var errInvalidSession = errors.New("Invalid session cookie.")
+
+// Derive a key for the given purpose from the app secret.
+func sessionKey(secret []byte, purpose string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(SessionCookie + " " + purpose))
+	return mac.Sum(nil)
+}
+
+// Encrypt the access token for the session cookie. The token is encrypted
+// with AES-CTR and authenticated with HMAC-SHA256, both using keys derived
+// from the app secret.
+func sealSession(secret []byte, accessToken string) (string, error) {
+	block, err := aes.NewCipher(sessionKey(secret, "encrypt"))
+	if err != nil {
+		return "", err
+	}
+	sealed := make([]byte, aes.BlockSize+len(accessToken))
+	iv := sealed[:aes.BlockSize]
+	if _, err := rand.Read(iv); err != nil {
+		return "", err
+	}
+	cipher.NewCTR(block, iv).XORKeyStream(sealed[aes.BlockSize:], []byte(accessToken))
+	mac := hmac.New(sha256.New, sessionKey(secret, "mac"))
+	mac.Write(sealed)
+	return base64.URLEncoding.EncodeToString(mac.Sum(sealed)), nil
+}
+
+// Decrypt an access token sealed by sealSession.
+func openSession(secret []byte, value string) (string, error) {
+	sealed, err := base64.URLEncoding.DecodeString(value)
+	if err != nil || len(sealed) < aes.BlockSize+sha256.Size {
+		return "", errInvalidSession
+	}
+	sig := sealed[len(sealed)-sha256.Size:]
+	sealed = sealed[:len(sealed)-sha256.Size]
+	mac := hmac.New(sha256.New, sessionKey(secret, "mac"))
+	mac.Write(sealed)
+	if !hmac.Equal(sig, mac.Sum(nil)) {
+		return "", errInvalidSession
+	}
+	block, err := aes.NewCipher(sessionKey(secret, "encrypt"))
+	if err != nil {
+		return "", err
+	}
+	accessToken := make([]byte, len(sealed)-aes.BlockSize)
+	cipher.NewCTR(block, sealed[:aes.BlockSize]).XORKeyStream(
+		accessToken, sealed[aes.BlockSize:])
+	return string(accessToken), nil
+}
+
+// Get the access token from the session cookie, if there is a valid one.
+// The secret must be the one of the Default app in the Handler registry.
+func SessionToken(secret []byte, r *http.Request) string {
+	cookie, err := r.Cookie(SessionCookie)
+	if err != nil {
+		return ""
+	}
+	accessToken, err := openSession(secret, cookie.Value)
+	if err != nil {
+		return ""
+	}
+	return accessToken
+}
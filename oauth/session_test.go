@@ -0,0 +1,44 @@
+package oauth
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestSessionRoundTrip(t *testing.T) {
+	t.Parallel()
+	secret := []byte("secret")
+	sealed, err := sealSession(secret, "the-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(sealed, "the-token") {
+		t.Fatalf("Was expecting the token to be encrypted, found %s", sealed)
+	}
+	r, _ := http.NewRequest("GET", "/", nil)
+	r.AddCookie(&http.Cookie{Name: SessionCookie, Value: sealed})
+	if token := SessionToken(secret, r); token != "the-token" {
+		t.Fatalf("Did not find expected token instead found %q", token)
+	}
+	if token := SessionToken([]byte("other"), r); token != "" {
+		t.Fatalf("Was not expecting a token with another secret, found %q", token)
+	}
+}
+
+func TestSessionTampered(t *testing.T) {
+	t.Parallel()
+	secret := []byte("secret")
+	sealed, err := sealSession(secret, "the-token")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tampered := []byte(sealed)
+	tampered[len(tampered)/2] ^= 1
+	cases := []string{"the-token", string(tampered), "", "abc"}
+	for _, c := range cases {
+		if _, err := openSession(secret, c); err == nil {
+			t.Fatalf("Was expecting an error opening %q", c)
+		}
+	}
+}
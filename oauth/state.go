@@ -0,0 +1,123 @@
+package oauth
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/daaku/go.errcode"
+)
+
+// How long a state token remains valid for.
+const stateTTL = 10 * time.Minute
+
+var (
+	errInvalidState = errcode.New(http.StatusBadRequest, "Invalid state.")
+	errExpiredState = errcode.New(
+		http.StatusBadRequest, "The login attempt has expired, please try again.")
+	errReusedState = errcode.New(
+		http.StatusBadRequest, "The login attempt has already been completed.")
+)
+
+// The state carried through the OAuth dialog. It is signed with the app
+// secret and bound to the browser that started the flow.
+type state struct {
+	Nonce   string `json:"n"`
+	Expires int64  `json:"e"`
+	Browser string `json:"b"`
+	Return  string `json:"r,omitempty"`
+}
+
+func newState(browser, returnTo string, now time.Time) (*state, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return &state{
+		Nonce:   hex.EncodeToString(b),
+		Expires: now.Add(stateTTL).Unix(),
+		Browser: browser,
+		Return:  returnTo,
+	}, nil
+}
+
+func stateMAC(secret, payload []byte) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write(payload)
+	return mac.Sum(nil)
+}
+
+// Encode and sign the state.
+func encodeState(secret []byte, s *state) (string, error) {
+	payload, err := json.Marshal(s)
+	if err != nil {
+		return "", err
+	}
+	enc := base64.URLEncoding
+	return enc.EncodeToString(payload) + "." +
+		enc.EncodeToString(stateMAC(secret, payload)), nil
+}
+
+// Verify and decode the state. This checks the signature, browser and expiry
+// but not whether it has been used.
+func decodeState(secret []byte, raw, browser string, now time.Time) (*state, error) {
+	parts := bytes.SplitN([]byte(raw), []byte("."), 2)
+	if len(parts) != 2 {
+		return nil, errInvalidState
+	}
+	enc := base64.URLEncoding
+	payload, err := enc.DecodeString(string(parts[0]))
+	if err != nil {
+		return nil, errInvalidState
+	}
+	sig, err := enc.DecodeString(string(parts[1]))
+	if err != nil {
+		return nil, errInvalidState
+	}
+	if !hmac.Equal(sig, stateMAC(secret, payload)) {
+		return nil, errInvalidState
+	}
+	s := &state{}
+	if err := json.Unmarshal(payload, s); err != nil {
+		return nil, errInvalidState
+	}
+	if s.Nonce == "" || s.Browser != browser {
+		return nil, errInvalidState
+	}
+	if now.Unix() > s.Expires {
+		return nil, errExpiredState
+	}
+	return s, nil
+}
+
+// Tracks the nonces that have been used until they expire.
+type nonceSet struct {
+	mutex sync.Mutex
+	used  map[string]int64
+}
+
+// Marks the nonce as used, returning false if it was already used.
+func (n *nonceSet) Use(s *state, now time.Time) bool {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+	if n.used == nil {
+		n.used = make(map[string]int64)
+	}
+	for nonce, expires := range n.used {
+		if now.Unix() > expires {
+			delete(n.used, nonce)
+		}
+	}
+	if _, ok := n.used[s.Nonce]; ok {
+		return false
+	}
+	n.used[s.Nonce] = s.Expires
+	return true
+}
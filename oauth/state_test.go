@@ -0,0 +1,83 @@
+package oauth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+var stateSecret = []byte("secret")
+
+func TestStateRoundTrip(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1000, 0)
+	s, err := newState("browser", "/examples/", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := encodeState(stateSecret, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	actual, err := decodeState(stateSecret, raw, "browser", now.Add(time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *actual != *s {
+		t.Fatalf("Did not find expected state %+v instead found %+v", s, actual)
+	}
+}
+
+func TestStateInvalid(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1000, 0)
+	s, err := newState("browser", "/examples/", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	raw, err := encodeState(stateSecret, s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cases := []struct {
+		secret   []byte
+		raw      string
+		browser  string
+		now      time.Time
+		expected error
+	}{
+		{stateSecret, raw, "other", now, errInvalidState},
+		{[]byte("other"), raw, "browser", now, errInvalidState},
+		{stateSecret, strings.Replace(raw, ".", ".x", 1), "browser", now, errInvalidState},
+		{stateSecret, "garbage", "browser", now, errInvalidState},
+		{stateSecret, "", "browser", now, errInvalidState},
+		{stateSecret, raw, "browser", now.Add(stateTTL + time.Second), errExpiredState},
+	}
+	for _, c := range cases {
+		_, err := decodeState(c.secret, c.raw, c.browser, c.now)
+		if err != c.expected {
+			t.Fatalf("Did not find expected error %v for %q instead found %v",
+				c.expected, c.raw, err)
+		}
+	}
+}
+
+func TestNonceSingleUse(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(1000, 0)
+	s, err := newState("browser", "", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var n nonceSet
+	if !n.Use(s, now) {
+		t.Fatal("Was expecting first use to be allowed.")
+	}
+	if n.Use(s, now) {
+		t.Fatal("Was expecting second use to be rejected.")
+	}
+	n.Use(&state{Nonce: "other", Expires: now.Unix()}, now.Add(stateTTL*2))
+	if len(n.used) != 1 {
+		t.Fatalf("Did not find expected expired nonces to be pruned: %v", n.used)
+	}
+}
@@ -0,0 +1,142 @@
+package og
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	imagesFile       = "images.txt"
+	descriptionsFile = "descriptions.txt"
+)
+
+// Pools of stock images and descriptions used to generate defaults for
+// objects that don't specify them. Images are names under the static
+// /images/ path. The pick from a pool is a hash of the object URL, so a
+// given URL always gets the same default as long as the pool is unchanged.
+type Defaults struct {
+	Images       []string
+	Descriptions []string
+
+	// Pools for specific og:type values, used instead of the ones above where
+	// they are not empty.
+	Types map[string]*Defaults
+}
+
+// The built-in pools.
+var StockDefaults = &Defaults{
+	Images:       stockImages,
+	Descriptions: stockDescriptions,
+}
+
+// Provides URLs for static files, failing for missing ones, such as
+// *static.Handler.
+type StaticURL interface {
+	URL(name string) (string, error)
+}
+
+// Load Defaults from a directory containing images.txt and descriptions.txt
+// with one entry per line, ignoring blank lines and lines starting with #.
+// Sub-directories named after an og:type contain the same files for that type.
+// Missing files fall back to the stock pools. Each image is checked against
+// the static handler so a typo fails here rather than when rendering.
+func LoadDefaults(dir string, static StaticURL) (*Defaults, error) {
+	d, err := loadPools(dir)
+	if err != nil {
+		return nil, err
+	}
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read OG defaults directory %s: %s", dir, err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		typed, err := loadPools(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		if d.Types == nil {
+			d.Types = make(map[string]*Defaults)
+		}
+		d.Types[entry.Name()] = typed
+	}
+	if err := d.checkImages(static); err != nil {
+		return nil, err
+	}
+	return d, nil
+}
+
+func (d *Defaults) checkImages(static StaticURL) error {
+	for _, image := range d.Images {
+		if _, err := static.URL("/images/" + image); err != nil {
+			return fmt.Errorf("Invalid OG default image %s: %s", image, err)
+		}
+	}
+	for _, typed := range d.Types {
+		if err := typed.checkImages(static); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func loadPools(dir string) (*Defaults, error) {
+	images, err := readLines(filepath.Join(dir, imagesFile))
+	if err != nil {
+		return nil, err
+	}
+	descriptions, err := readLines(filepath.Join(dir, descriptionsFile))
+	if err != nil {
+		return nil, err
+	}
+	return &Defaults{Images: images, Descriptions: descriptions}, nil
+}
+
+// Read the non empty, non comment lines from a file. A missing file has no
+// lines.
+func readLines(name string) ([]string, error) {
+	content, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read OG defaults file %s: %s", name, err)
+	}
+	var lines []string
+	for _, line := range strings.Split(string(content), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		lines = append(lines, line)
+	}
+	return lines, nil
+}
+
+// The image and description pools for the og:type, preferring the type
+// specific ones and falling back to the stock pools.
+func (d *Defaults) pools(ogType string) ([]string, []string) {
+	if d == nil {
+		d = StockDefaults
+	}
+	typed := d.Types[ogType]
+	if typed == nil {
+		typed = &Defaults{}
+	}
+	return firstNonEmpty(typed.Images, d.Images, stockImages),
+		firstNonEmpty(typed.Descriptions, d.Descriptions, stockDescriptions)
+}
+
+func firstNonEmpty(choices ...[]string) []string {
+	for _, c := range choices {
+		if len(c) > 0 {
+			return c
+		}
+	}
+	return nil
+}
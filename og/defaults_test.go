@@ -0,0 +1,120 @@
+package og
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daaku/go.fbapp"
+
+	"github.com/daaku/rell/context"
+)
+
+func writeFile(t *testing.T, name, content string) {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(name, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// Static files that exist by name.
+type fakeStatic map[string]bool
+
+func (f fakeStatic) URL(name string) (string, error) {
+	if !f[name] {
+		return "", fmt.Errorf("%s not found", name)
+	}
+	return name, nil
+}
+
+func TestLoadDefaults(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "og-defaults-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile(t, filepath.Join(dir, descriptionsFile), "# comment\nfoo\n\n  bar  \n")
+	writeFile(t, filepath.Join(dir, "music.song", imagesFile), "song.jpg\n")
+
+	d, err := LoadDefaults(dir, fakeStatic{"/images/song.jpg": true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	images, descriptions := d.pools("article")
+	if !reflect.DeepEqual(images, stockImages) {
+		t.Fatalf("Did not find expected stock images, found %v", images)
+	}
+	if !reflect.DeepEqual(descriptions, []string{"foo", "bar"}) {
+		t.Fatalf("Did not find expected descriptions, found %v", descriptions)
+	}
+	images, descriptions = d.pools("music.song")
+	if !reflect.DeepEqual(images, []string{"song.jpg"}) {
+		t.Fatalf("Did not find expected music.song images, found %v", images)
+	}
+	if !reflect.DeepEqual(descriptions, []string{"foo", "bar"}) {
+		t.Fatalf("Did not find expected descriptions, found %v", descriptions)
+	}
+}
+
+func TestLoadDefaultsMissingDir(t *testing.T) {
+	t.Parallel()
+	if _, err := LoadDefaults("/does/not/exist", fakeStatic{}); err == nil {
+		t.Fatal("Was expecting an error for a missing directory")
+	}
+}
+
+func TestLoadDefaultsMissingImage(t *testing.T) {
+	t.Parallel()
+	dir, err := ioutil.TempDir("", "og-defaults-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	writeFile(t, filepath.Join(dir, imagesFile), "a.jpg\n")
+	writeFile(t, filepath.Join(dir, "book", imagesFile), "typo.jpg\n")
+	_, err = LoadDefaults(dir, fakeStatic{"/images/a.jpg": true})
+	if err == nil || !strings.Contains(err.Error(), "typo.jpg") {
+		t.Fatalf("Was expecting an error for the missing image, found %v", err)
+	}
+}
+
+func TestStockDefaultsUnchanged(t *testing.T) {
+	t.Parallel()
+	var d *Defaults
+	images, descriptions := d.pools("article")
+	if !reflect.DeepEqual(images, stockImages) ||
+		!reflect.DeepEqual(descriptions, stockDescriptions) {
+		t.Fatalf("Did not find expected stock pools, found %v %v", images, descriptions)
+	}
+	const url = "http://www.fbrell.com/og/article/foo"
+	if pick := hashedPick(url, descriptions); pick != stockDescriptions[6] {
+		t.Fatalf("Did not find expected stable description, found %s", pick)
+	}
+}
+
+func TestTypedDescription(t *testing.T) {
+	t.Parallel()
+	p := &Parser{Defaults: &Defaults{
+		Types: map[string]*Defaults{
+			"book": {Descriptions: []string{"A book."}},
+		},
+	}}
+	c := (&context.Parser{App: fbapp.New(184484190795, "", "fbrell")}).Default()
+	object, err := p.FromPairs(c, []Pair{
+		{Key: "og:type", Value: "book"},
+		{Key: "og:image", Value: "http://www.fbrell.com/a.jpg"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if object.Description() != "A book." {
+		t.Fatalf("Did not find expected description, found %s", object.Description())
+	}
+}
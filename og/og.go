@@ -1,5 +1,6 @@
 // Package og implements the URL based Rell OG abstraction. It allows
-// for a human readable and a base64 version API as input.
+// for a human readable and a base64 version API as input, as well as
+// definitions stored server side.
 package og
 
 import (
@@ -9,11 +10,13 @@ import (
 	"fmt"
 	"io"
 	"log"
+	"net/http"
 	"net/url"
 	"sort"
 	"strconv"
 	"strings"
 
+	"github.com/daaku/go.errcode"
 	"github.com/daaku/go.fburl"
 	"github.com/daaku/go.static"
 
@@ -22,8 +25,8 @@ import (
 
 // The representation of of <meta property="{key}" content="{value}">.
 type Pair struct {
-	Key   string
-	Value string
+	Key   string `json:"key"`
+	Value string `json:"value"`
 }
 
 // An ordered list of Pairs representing a raw Object.
@@ -31,6 +34,7 @@ type Object struct {
 	Pairs        []Pair
 	context      *context.Context
 	static       *static.Handler
+	defaults     *Defaults
 	skipGenerate []string
 }
 
@@ -43,125 +47,175 @@ func fixPadding(b64 string) string {
 	return b64
 }
 
-// Sorted query string encoding
-func sortedEncode(v url.Values) string {
-	if v == nil {
-		return ""
-	}
-	parts := make([]string, 0, len(v)) // will be large enough for most uses
-	for k, vs := range v {
-		prefix := url.QueryEscape(k) + "="
-		for _, v := range vs {
-			parts = append(parts, prefix+url.QueryEscape(v))
-		}
+// Parameters Facebook adds to shared URLs, which are left out of the
+// generated og:url along with the og:type and og:title from the path.
+func skipURLParam(key string) bool {
+	switch key {
+	case "og:type", "og:title":
+	case "action_object_map":
+	case "action_ref_map":
+	case "action_type_map":
+	case "fb_action_ids":
+	case "fb_action_types":
+	case "fb_aggregation_id":
+	case "fb_locale":
+	case "fb_source":
+	case "ref":
+	case "refid":
+	default:
+		return false
 	}
-	sort.Strings(parts)
-	return strings.Join(parts, "&")
+	return true
 }
 
-// Make a copy of url.Values.
-func copyValues(source url.Values) url.Values {
-	dest := url.Values{}
-	for key, _ := range source {
-		switch key {
-		case "action_object_map":
-		case "action_ref_map":
-		case "action_type_map":
-		case "fb_action_ids":
-		case "fb_action_types":
-		case "fb_aggregation_id":
-		case "fb_locale":
-		case "fb_source":
-		case "ref":
-		case "refid":
-			continue
-		default:
-			dup := make([]string, len(source[key]))
-			copy(dup, source[key])
-			dest[key] = dup
-		}
-	}
-	return dest
+type Parser struct {
+	Static   *static.Handler
+	Store    *Store
+	Defaults *Defaults // nil uses StockDefaults
 }
 
-type Parser struct {
-	Static *static.Handler
+func (p *Parser) newObject(context *context.Context) *Object {
+	return &Object{
+		context:  context,
+		static:   p.Static,
+		defaults: p.Defaults,
+	}
 }
 
 // Create a new Object from Base64 JSON encoded data.
 func (p *Parser) FromBase64(context *context.Context, b64 string) (*Object, error) {
+	pairs, noDefault, err := DecodeBase64(b64)
+	if err != nil {
+		return nil, err
+	}
+	return p.fromRows(
+		context, pairs, noDefault, context.AbsoluteURL("/rog/"+b64).String())
+}
+
+// Decode the Base64 JSON encoded rows used in /rog/ URLs. Returns the pairs
+// and the keys of the [key, null] rows, which skip the generated defaults.
+func DecodeBase64(b64 string) ([]Pair, []string, error) {
 	jsonBytes, err := base64.URLEncoding.DecodeString(fixPadding(b64))
 	if err != nil {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"Failed base64 decode of string \"%s\" with error: %s", b64, err)
 	}
+	return decodeRows(jsonBytes)
+}
+
+// Create a new Object from a definition in the Store.
+func (p *Parser) FromID(context *context.Context, id string) (*Object, error) {
+	if !IsStoredID(id) {
+		return nil, errcode.New(http.StatusNotFound, "Invalid ID: %s", id)
+	}
+	jsonBytes, err := p.Store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	if jsonBytes == nil {
+		return nil, errcode.New(http.StatusNotFound, "Object not found: %s", id)
+	}
+	pairs, noDefault, err := decodeRows(jsonBytes)
+	if err != nil {
+		return nil, err
+	}
+	return p.fromRows(
+		context, pairs, noDefault, context.AbsoluteURL("/sog/"+id).String())
+}
+
+// Decode JSON encoded [key, value] rows into the pairs and the keys of the
+// rows with a null value.
+func decodeRows(jsonBytes []byte) ([]Pair, []string, error) {
 	var strSlices [][]interface{}
-	err = json.Unmarshal(jsonBytes, &strSlices)
+	err := json.Unmarshal(jsonBytes, &strSlices)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return nil, nil, fmt.Errorf(
 			"Failed json unmarshal string %s with error %s", string(jsonBytes), err)
 	}
 
-	object := &Object{
-		context: context,
-		static:  p.Static,
-	}
+	var pairs []Pair
+	var noDefault []string
 	for _, row := range strSlices {
 		if len(row) != 2 {
-			return nil, fmt.Errorf("Got more than two elements in pair: %v", row)
+			return nil, nil, fmt.Errorf("Got more than two elements in pair: %v", row)
 		}
 		if row[0] == nil {
-			return nil, fmt.Errorf("First element in pair is null: %v", row)
+			return nil, nil, fmt.Errorf("First element in pair is null: %v", row)
 		}
 		key := fmt.Sprint(row[0])
 		val := ""
 		switch t := row[1].(type) {
 		case nil:
-			object.skipGenerate = append(object.skipGenerate, key)
+			noDefault = append(noDefault, key)
 			continue
 		case float64:
 			val = fmt.Sprint(uint64(t))
 		default:
 			val = fmt.Sprint(t)
 		}
-		object.AddPair(key, val)
+		pairs = append(pairs, Pair{Key: key, Value: val})
+	}
+	return pairs, noDefault, nil
+}
+
+// Create a new Object from decoded rows, using ogURL as the default og:url.
+func (p *Parser) fromRows(context *context.Context, pairs []Pair, noDefault []string, ogURL string) (*Object, error) {
+	object := p.newObject(context)
+	object.skipGenerate = noDefault
+	for _, pair := range pairs {
+		object.AddPair(pair.Key, pair.Value)
 	}
 
 	if object.shouldGenerate("og:url") {
-		url := context.AbsoluteURL("/rog/" + b64).String()
-		object.AddPair("og:url", url)
+		object.AddPair("og:url", ogURL)
 	}
 
-	err = object.generateDefaults()
+	err := object.generateDefaults()
 	if err != nil {
 		return nil, err
 	}
 	return object, nil
 }
 
-// Create a new Object from query string data.
+// Create a new Object from query string data. Since url.Values does not
+// preserve order, the properties are sorted by key. Use FromPairs where the
+// order is known.
 func (p *Parser) FromValues(context *context.Context, values url.Values) (*Object, error) {
-	object := &Object{
-		context: context,
-		static:  p.Static,
-	}
-	for key, values := range values {
-		if strings.Contains(key, ":") {
-			for _, value := range values {
-				object.AddPair(key, value)
-			}
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var pairs []Pair
+	for _, key := range keys {
+		for _, value := range values[key] {
+			pairs = append(pairs, Pair{Key: key, Value: value})
+		}
+	}
+	return p.FromPairs(context, pairs)
+}
+
+// Create a new Object from ordered query string data, as returned by
+// ParseQuery. Pairs whose key does not contain a ":" are not properties but
+// still contribute to the generated og:url.
+func (p *Parser) FromPairs(context *context.Context, pairs []Pair) (*Object, error) {
+	object := p.newObject(context)
+	var query []Pair
+	for _, pair := range pairs {
+		if strings.Contains(pair.Key, ":") {
+			object.AddPair(pair.Key, pair.Value)
+		}
+		if !skipURLParam(pair.Key) {
+			query = append(query, pair)
 		}
 	}
 
 	if object.shouldGenerate("og:url") {
-		copiedValues := copyValues(values)
-		copiedValues.Del("og:type")
-		copiedValues.Del("og:title")
 		url := url.URL{
 			Scheme:   context.Scheme,
 			Host:     context.Host,
 			Path:     "/og/" + object.Type() + "/" + object.Title(),
-			RawQuery: sortedEncode(copiedValues),
+			RawQuery: sortedEncodeQuery(query),
 		}
 		object.AddPair("og:url", url.String())
 	}
@@ -180,6 +234,59 @@ func (p *Parser) FromValues(context *context.Context, values url.Values) (*Objec
 	return object, nil
 }
 
+// Parse a raw query string into Pairs. Unlike url.ParseQuery this preserves
+// the order of the parameters, which structured properties depend on.
+// Malformed parameters are skipped.
+func ParseQuery(query string) []Pair {
+	var pairs []Pair
+	for query != "" {
+		key := query
+		if i := strings.IndexAny(key, "&;"); i >= 0 {
+			key, query = key[:i], key[i+1:]
+		} else {
+			query = ""
+		}
+		if key == "" {
+			continue
+		}
+		value := ""
+		if i := strings.Index(key, "="); i >= 0 {
+			key, value = key[:i], key[i+1:]
+		}
+		key, err := url.QueryUnescape(key)
+		if err != nil {
+			continue
+		}
+		value, err = url.QueryUnescape(value)
+		if err != nil {
+			continue
+		}
+		pairs = append(pairs, Pair{Key: key, Value: value})
+	}
+	return pairs
+}
+
+// Encode the Pairs as a query string, preserving their order.
+func EncodeQuery(pairs []Pair) string {
+	return strings.Join(encodePairs(pairs), "&")
+}
+
+// Encode the pairs as a query string sorted by the encoded parameters, which
+// keeps the generated og:url the same regardless of the order in the URL.
+func sortedEncodeQuery(pairs []Pair) string {
+	parts := encodePairs(pairs)
+	sort.Strings(parts)
+	return strings.Join(parts, "&")
+}
+
+func encodePairs(pairs []Pair) []string {
+	parts := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		parts = append(parts, url.QueryEscape(pair.Key)+"="+url.QueryEscape(pair.Value))
+	}
+	return parts
+}
+
 func (o *Object) shouldGenerate(key string) bool {
 	if len(o.GetAll(key)) > 0 {
 		return false
@@ -194,15 +301,16 @@ func (o *Object) shouldGenerate(key string) bool {
 
 func (o *Object) generateDefaults() error {
 	url := o.URL()
-	if o.shouldGenerate("og:image") {
-		img, err := o.static.URL("/images/" + hashedPick(url, stockImages))
+	images, descriptions := o.defaults.pools(o.Type())
+	if o.shouldGenerate("og:image") && len(o.Images()) == 0 {
+		img, err := o.static.URL("/images/" + hashedPick(url, images))
 		if err != nil {
 			return err
 		}
 		o.AddPair("og:image", o.context.AbsoluteURL(img).String())
 	}
 	if o.shouldGenerate("og:description") {
-		o.AddPair("og:description", hashedPick(url, stockDescriptions))
+		o.AddPair("og:description", hashedPick(url, descriptions))
 	}
 	return nil
 }
@@ -227,9 +335,12 @@ func (o *Object) Description() string {
 	return o.Get("og:description")
 }
 
-// Get the first "og:image" value.
+// Get the URL of the first image.
 func (o *Object) ImageURL() string {
-	return o.Get("og:image")
+	if images := o.Images(); len(images) > 0 {
+		return images[0].URL
+	}
+	return ""
 }
 
 // Get the first "fb:app_id" value.
@@ -0,0 +1,152 @@
+package og
+
+import (
+	"bytes"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/daaku/go.errcode"
+
+	"github.com/daaku/rell/examples"
+)
+
+const (
+	storeKeyPrefix = "fbrell_og:"
+
+	// Length of the hex IDs for stored objects.
+	storedIDLength = 16
+
+	maxStoredSize = 16384
+)
+
+var (
+	errStoredTooLarge = errcode.New(
+		http.StatusRequestEntityTooLarge,
+		"Maximum allowed size is %d kilobytes.", maxStoredSize/1024)
+	errStoredCollision = errcode.New(
+		http.StatusConflict, "A different object is stored with the same ID.")
+)
+
+// Stores object definitions server side addressed by a short content hash,
+// which keeps /sog/ URLs within scraper limits regardless of the number of
+// properties.
+type Store struct {
+	ByteStore examples.ByteStore
+
+	// Stored objects not accessed within the TTL expire if the ByteStore is
+	// an examples.ExpireByteStore. Otherwise they are kept forever.
+	TTL time.Duration
+}
+
+// Save a JSON object definition, returning its ID. The definition is either
+// the /rog/ form of [key, value] rows, or a list of {"key": k, "value": v}
+// objects as returned by the JSON API.
+func (s *Store) Save(definition []byte) (string, error) {
+	rows, err := parseDefinition(definition)
+	if err != nil {
+		return "", err
+	}
+	canonical, err := json.Marshal(rows)
+	if err != nil {
+		return "", err
+	}
+	if len(canonical) > maxStoredSize {
+		return "", errStoredTooLarge
+	}
+	id := StoredID(canonical)
+	existing, err := s.Get(id)
+	if err != nil {
+		return "", err
+	}
+	if existing != nil {
+		if !bytes.Equal(existing, canonical) {
+			return "", errStoredCollision
+		}
+		return id, nil
+	}
+	if err := s.ByteStore.Store(storeKeyPrefix+id, canonical); err != nil {
+		return "", err
+	}
+	return id, s.expire(id)
+}
+
+// Get the stored JSON definition, refreshing its TTL. Returns nil if it does
+// not exist.
+func (s *Store) Get(id string) ([]byte, error) {
+	definition, err := s.ByteStore.Get(storeKeyPrefix + id)
+	if err != nil || definition == nil {
+		return nil, err
+	}
+	return definition, s.expire(id)
+}
+
+// Set the stored object to expire once the TTL has passed, if the store can
+// expire keys.
+func (s *Store) expire(id string) error {
+	es, ok := s.ByteStore.(examples.ExpireByteStore)
+	if !ok || s.TTL <= 0 {
+		return nil
+	}
+	return es.Expire(storeKeyPrefix+id, s.TTL)
+}
+
+// The ID for a canonical JSON definition.
+func StoredID(canonical []byte) string {
+	h := md5.New()
+	h.Write(canonical)
+	return fmt.Sprintf("%x", h.Sum(nil))[:storedIDLength]
+}
+
+// Check if the given string looks like an ID generated by StoredID.
+func IsStoredID(id string) bool {
+	if len(id) != storedIDLength {
+		return false
+	}
+	for _, c := range id {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// Parse a JSON definition into [key, value] rows, checking it in the same way
+// FromBase64 does.
+func parseDefinition(definition []byte) ([][]interface{}, error) {
+	var elements []json.RawMessage
+	if err := json.Unmarshal(definition, &elements); err != nil {
+		return nil, errcode.New(
+			http.StatusBadRequest, "Expected a JSON list of properties: %s", err)
+	}
+	rows := make([][]interface{}, 0, len(elements))
+	for _, element := range elements {
+		var row []interface{}
+		if err := json.Unmarshal(element, &row); err != nil {
+			var pair map[string]interface{}
+			if err := json.Unmarshal(element, &pair); err != nil {
+				return nil, errcode.New(http.StatusBadRequest,
+					"Expected a [key, value] row or a key/value object: %s", element)
+			}
+			row = []interface{}{pair["key"], pair["value"]}
+		}
+		if len(row) != 2 {
+			return nil, errcode.New(http.StatusBadRequest,
+				"Expected two elements in pair: %s", element)
+		}
+		if _, ok := row[0].(string); !ok {
+			return nil, errcode.New(http.StatusBadRequest,
+				"First element in pair is not a string: %s", element)
+		}
+		switch row[1].(type) {
+		case nil, string, float64:
+		default:
+			return nil, errcode.New(http.StatusBadRequest,
+				"Second element in pair is not a string, number or null: %s", element)
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
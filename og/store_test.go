@@ -0,0 +1,124 @@
+package og
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/daaku/go.errcode"
+	"github.com/daaku/go.fbapp"
+
+	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/examples"
+	"github.com/daaku/rell/examples/memstore"
+)
+
+func TestStoreRoundTrip(t *testing.T) {
+	t.Parallel()
+	store := &Store{ByteStore: &memstore.Store{}}
+	rows := []byte(`[["og:type","article"],["og:title","foo"],["og:image",null],["fb:app_id",184484190795],["og:description","bar"]]`)
+	id, err := store.Save(rows)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsStoredID(id) {
+		t.Fatalf("Did not find expected ID format, found %s", id)
+	}
+	objects := []byte(`[{"key":"og:type","value":"article"},{"key":"og:title","value":"foo"},
+		{"key":"og:image","value":null},{"key":"fb:app_id","value":184484190795},
+		{"key":"og:description","value":"bar"}]`)
+	if other, err := store.Save(objects); err != nil || other != id {
+		t.Fatalf("Did not find expected ID %s for the object form, found %s %v", id, other, err)
+	}
+
+	c := (&context.Parser{App: fbapp.New(184484190795, "", "fbrell")}).Default()
+	object, err := (&Parser{Store: store}).FromID(c, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Pair{
+		{Key: "og:type", Value: "article"},
+		{Key: "og:title", Value: "foo"},
+		{Key: "fb:app_id", Value: "184484190795"},
+		{Key: "og:description", Value: "bar"},
+		{Key: "og:url", Value: "http://www.fbrell.com/sog/" + id},
+	}
+	if len(object.Pairs) != len(expected) {
+		t.Fatalf("Did not find expected pairs %v, found %v", expected, object.Pairs)
+	}
+	for i, pair := range expected {
+		if object.Pairs[i] != pair {
+			t.Fatalf("Did not find expected pair %v, found %v", pair, object.Pairs[i])
+		}
+	}
+}
+
+func TestStoreInvalid(t *testing.T) {
+	t.Parallel()
+	store := &Store{ByteStore: &memstore.Store{}}
+	cases := []string{
+		`{"og:type":"article"}`,
+		`[["og:type"]]`,
+		`[[null,"article"]]`,
+		`[["og:type",["article"]]]`,
+		`[1]`,
+	}
+	for _, c := range cases {
+		_, err := store.Save([]byte(c))
+		if err == nil {
+			t.Fatalf("Was expecting an error for %s", c)
+		}
+		if e, ok := err.(errcode.E); !ok || e.Code() != http.StatusBadRequest {
+			t.Fatalf("Did not find expected bad request error for %s, found %v", c, err)
+		}
+	}
+}
+
+func TestFromIDMissing(t *testing.T) {
+	t.Parallel()
+	p := &Parser{Store: &Store{ByteStore: &memstore.Store{}}}
+	c := (&context.Parser{App: fbapp.New(184484190795, "", "fbrell")}).Default()
+	for _, id := range []string{"0123456789abcdef", "nope"} {
+		_, err := p.FromID(c, id)
+		if e, ok := err.(errcode.E); !ok || e.Code() != http.StatusNotFound {
+			t.Fatalf("Did not find expected not found error for %s, found %v", id, err)
+		}
+	}
+}
+
+// A store that records the keys it was asked to expire.
+type expireStore struct {
+	examples.ByteStore
+	expired map[string]time.Duration
+}
+
+func (s *expireStore) Expire(key string, ttl time.Duration) error {
+	s.expired[key] = ttl
+	return nil
+}
+
+func TestStoreExpires(t *testing.T) {
+	t.Parallel()
+	bs := &expireStore{ByteStore: &memstore.Store{}, expired: map[string]time.Duration{}}
+	store := &Store{ByteStore: bs, TTL: time.Hour}
+	id, err := store.Save([]byte(`[["og:type","article"]]`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bs.expired[storeKeyPrefix+id] != time.Hour {
+		t.Fatalf("Did not find expected expiry for %s instead found %v", id, bs.expired)
+	}
+	delete(bs.expired, storeKeyPrefix+id)
+	if _, err := store.Get(id); err != nil {
+		t.Fatal(err)
+	}
+	if bs.expired[storeKeyPrefix+id] != time.Hour {
+		t.Fatalf("Was expecting Get to refresh the expiry, found %v", bs.expired)
+	}
+	if _, err := store.Get("0123456789abcdef"); err != nil {
+		t.Fatal(err)
+	}
+	if len(bs.expired) != 1 {
+		t.Fatalf("Was not expecting a missing object to expire, found %v", bs.expired)
+	}
+}
@@ -0,0 +1,63 @@
+package og
+
+import (
+	"strings"
+)
+
+// A structured property such as og:image, along with the sub-properties like
+// og:image:width that follow it. Properties are keyed by the sub-property
+// name, for example "width", and are in the order they were specified.
+type Structured struct {
+	Property   string `json:"property"`
+	URL        string `json:"url"`
+	Properties []Pair `json:"properties,omitempty"`
+}
+
+// Get the first value for the sub-property name.
+func (s *Structured) Get(name string) string {
+	for _, pair := range s.Properties {
+		if pair.Key == name {
+			return pair.Value
+		}
+	}
+	return ""
+}
+
+// Get all the values for the structured property, for example "og:image".
+// Each occurrence of the property, or of its ":url" sub-property, starts a new
+// value and the sub-properties that follow are grouped with it. Sub-properties
+// that do not follow a value are ignored, Validate reports them.
+func (o *Object) Structured(property string) []*Structured {
+	var results []*Structured
+	var current *Structured
+	prefix := property + ":"
+	for _, pair := range o.Pairs {
+		if pair.Key == property || pair.Key == prefix+"url" {
+			current = &Structured{Property: property, URL: pair.Value}
+			results = append(results, current)
+			continue
+		}
+		if current != nil && strings.HasPrefix(pair.Key, prefix) {
+			current.Properties = append(current.Properties, Pair{
+				Key:   pair.Key[len(prefix):],
+				Value: pair.Value,
+			})
+		}
+	}
+	return results
+}
+
+// Get all the og:image values.
+func (o *Object) Images() []*Structured {
+	return o.Structured("og:image")
+}
+
+// Get all the og:video values.
+func (o *Object) Videos() []*Structured {
+	return o.Structured("og:video")
+}
+
+// Get all the og:audio values.
+func (o *Object) Audios() []*Structured {
+	return o.Structured("og:audio")
+}
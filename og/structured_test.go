@@ -0,0 +1,98 @@
+package og
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/daaku/go.fbapp"
+
+	"github.com/daaku/rell/context"
+)
+
+func TestParseQueryOrder(t *testing.T) {
+	t.Parallel()
+	const query = "og:image=a.jpg&og:image:width=100&og:image=b.jpg;foo&og%3Atitle=a+b&bad=%zz"
+	expected := []Pair{
+		{Key: "og:image", Value: "a.jpg"},
+		{Key: "og:image:width", Value: "100"},
+		{Key: "og:image", Value: "b.jpg"},
+		{Key: "foo", Value: ""},
+		{Key: "og:title", Value: "a b"},
+	}
+	pairs := ParseQuery(query)
+	if !reflect.DeepEqual(pairs, expected) {
+		t.Fatalf("Did not find expected pairs %v instead found %v", expected, pairs)
+	}
+	if encoded := EncodeQuery(pairs[:3]); encoded != "og%3Aimage=a.jpg&og%3Aimage%3Awidth=100&og%3Aimage=b.jpg" {
+		t.Fatalf("Did not find expected encoding, found %s", encoded)
+	}
+}
+
+func TestStructured(t *testing.T) {
+	t.Parallel()
+	o := &Object{Pairs: []Pair{
+		{Key: "og:image:width", Value: "1"},
+		{Key: "og:image", Value: "http://www.fbrell.com/a.jpg"},
+		{Key: "og:image:width", Value: "100"},
+		{Key: "og:image:height", Value: "200"},
+		{Key: "og:title", Value: "foo"},
+		{Key: "og:image:url", Value: "http://www.fbrell.com/b.jpg"},
+		{Key: "og:image:type", Value: "image/jpeg"},
+		{Key: "og:video", Value: "http://www.fbrell.com/a.mp4"},
+	}}
+	images := o.Images()
+	if len(images) != 2 {
+		t.Fatalf("Did not find expected 2 images, found %+v", images)
+	}
+	if images[0].URL != "http://www.fbrell.com/a.jpg" || images[0].Get("width") != "100" ||
+		images[0].Get("height") != "200" || images[0].Get("type") != "" {
+		t.Fatalf("Did not find expected first image, found %+v", images[0])
+	}
+	if images[1].URL != "http://www.fbrell.com/b.jpg" || images[1].Get("type") != "image/jpeg" {
+		t.Fatalf("Did not find expected second image, found %+v", images[1])
+	}
+	if videos := o.Videos(); len(videos) != 1 || len(videos[0].Properties) != 0 {
+		t.Fatalf("Did not find expected video, found %+v", videos)
+	}
+	if audios := o.Audios(); len(audios) != 0 {
+		t.Fatalf("Was not expecting audios, found %+v", audios)
+	}
+}
+
+func TestImageURLFromSubProperty(t *testing.T) {
+	t.Parallel()
+	o := &Object{Pairs: []Pair{
+		{Key: "og:image:url", Value: "http://www.fbrell.com/a.jpg"},
+	}}
+	if url := o.ImageURL(); url != "http://www.fbrell.com/a.jpg" {
+		t.Fatalf("Did not find expected image URL, found %s", url)
+	}
+}
+
+func TestFromPairsOrder(t *testing.T) {
+	t.Parallel()
+	c := (&context.Parser{App: fbapp.New(184484190795, "", "fbrell")}).Default()
+	pairs := []Pair{
+		{Key: "og:type", Value: "article"},
+		{Key: "og:title", Value: "foo"},
+		{Key: "og:image", Value: "http://www.fbrell.com/b.jpg"},
+		{Key: "og:image:width", Value: "200"},
+		{Key: "og:image", Value: "http://www.fbrell.com/a.jpg"},
+		{Key: "og:image:width", Value: "100"},
+		{Key: "og:description", Value: "bar"},
+	}
+	object, err := (&Parser{}).FromPairs(c, pairs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(object.Pairs[:len(pairs)], pairs) {
+		t.Fatalf("Did not find expected pairs in order %v, found %v", pairs, object.Pairs)
+	}
+	const url = "http://www.fbrell.com/og/article/foo?og%3Adescription=bar&" +
+		"og%3Aimage%3Awidth=100&og%3Aimage%3Awidth=200&" +
+		"og%3Aimage=http%3A%2F%2Fwww.fbrell.com%2Fa.jpg&" +
+		"og%3Aimage=http%3A%2F%2Fwww.fbrell.com%2Fb.jpg"
+	if object.URL() != url {
+		t.Fatalf("Did not find expected og:url %s, found %s", url, object.URL())
+	}
+}
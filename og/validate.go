@@ -0,0 +1,191 @@
+package og
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Lint levels.
+const (
+	LintError   = "error"
+	LintWarning = "warning"
+)
+
+// A problem found when validating an Object.
+type Lint struct {
+	Level    string `json:"level"`
+	Property string `json:"property,omitempty"`
+	Message  string `json:"message"`
+}
+
+var (
+	requiredProperties = []string{"og:type", "og:title", "og:url", "og:image"}
+
+	singleProperties = []string{
+		"og:type", "og:title", "og:url", "og:description", "fb:app_id",
+	}
+
+	// Structured properties and their allowed sub-properties.
+	structuredProperties = map[string][]string{
+		"og:image": {"url", "secure_url", "type", "width", "height"},
+		"og:video": {"url", "secure_url", "type", "width", "height"},
+		"og:audio": {"url", "secure_url", "type"},
+	}
+
+	// Properties whose values must be absolute URLs.
+	urlProperties = map[string]bool{
+		"og:url":              true,
+		"og:image":            true,
+		"og:image:url":        true,
+		"og:image:secure_url": true,
+		"og:video":            true,
+		"og:video:url":        true,
+		"og:video:secure_url": true,
+		"og:audio":            true,
+		"og:audio:url":        true,
+		"og:audio:secure_url": true,
+	}
+
+	globalTypes = []string{
+		"website", "article", "book", "profile",
+		"music.song", "music.album", "music.playlist", "music.radio_station",
+		"video.movie", "video.episode", "video.tv_show", "video.other",
+	}
+
+	// Namespaces not owned by any application.
+	globalNamespaces = []string{
+		"og", "fb", "al", "article", "book", "books", "business", "fitness",
+		"game", "games", "music", "place", "product", "profile", "restaurant",
+		"video",
+	}
+)
+
+// Validate the Object against the known Open Graph rules.
+func (o *Object) Validate() []Lint {
+	var lints []Lint
+	add := func(level, property, format string, args ...interface{}) {
+		lints = append(lints, Lint{
+			Level:    level,
+			Property: property,
+			Message:  fmt.Sprintf(format, args...),
+		})
+	}
+
+	for _, key := range requiredProperties {
+		if key == "og:image" && o.ImageURL() != "" {
+			continue
+		}
+		if o.Get(key) == "" {
+			add(LintError, key, "The required property %s is missing.", key)
+		}
+	}
+	for _, key := range singleProperties {
+		if count := len(o.GetAll(key)); count > 1 {
+			add(LintWarning, key,
+				"%s should only be specified once but was found %d times.", key, count)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, pair := range o.Pairs {
+		key, value := pair.Key, pair.Value
+		if parent, sub := structuredParent(key); parent != "" {
+			// The :url sub-property is an alternative to the parent itself.
+			if sub == "url" {
+				seen[parent] = true
+			} else if !seen[parent] {
+				add(LintError, key, "%s must follow a %s property.", key, parent)
+			}
+			if !contains(structuredProperties[parent], sub) {
+				add(LintWarning, key, "%s is not a known %s property.", key, parent)
+			}
+			if sub == "width" || sub == "height" {
+				if _, err := strconv.ParseUint(value, 10, 64); err != nil {
+					add(LintError, key, "%s must be a non-negative integer, got %q.", key, value)
+				}
+			}
+		}
+		if urlProperties[key] {
+			if u, err := url.Parse(value); err != nil || !u.IsAbs() ||
+				(u.Scheme != "http" && u.Scheme != "https") {
+				add(LintError, key, "%s must be an absolute http or https URL, got %q.", key, value)
+			} else if strings.HasSuffix(key, ":secure_url") && u.Scheme != "https" {
+				add(LintError, key, "%s must be an https URL, got %q.", key, value)
+			}
+		}
+		if ns := namespace(key); ns != "" && !o.ownsNamespace(ns) {
+			add(LintWarning, key,
+				"%s uses the namespace %s which does not belong to the application%s.",
+				key, ns, o.appNamespaceNote())
+		}
+		seen[key] = true
+	}
+
+	if appID := o.AppID(); appID != "" {
+		if _, err := strconv.ParseUint(appID, 10, 64); err != nil {
+			add(LintError, "fb:app_id", "fb:app_id must be numeric, got %q.", appID)
+		}
+	}
+
+	if ogType := o.Type(); ogType != "" {
+		if i := strings.Index(ogType, ":"); i > -1 {
+			if ns := ogType[:i]; !o.ownsNamespace(ns) {
+				add(LintWarning, "og:type",
+					"og:type %s uses the namespace %s which does not belong to the application%s.",
+					ogType, ns, o.appNamespaceNote())
+			}
+		} else if !contains(globalTypes, ogType) {
+			add(LintWarning, "og:type",
+				"og:type %s is not a known global type or namespaced custom type.", ogType)
+		}
+	}
+	return lints
+}
+
+// Returns the parent and sub-property for structured properties like
+// og:image:width.
+func structuredParent(key string) (string, string) {
+	for parent := range structuredProperties {
+		if strings.HasPrefix(key, parent+":") {
+			return parent, key[len(parent)+1:]
+		}
+	}
+	return "", ""
+}
+
+// The namespace for a property, ignoring those that aren't namespaced.
+func namespace(key string) string {
+	if i := strings.Index(key, ":"); i > 0 {
+		return key[:i]
+	}
+	return ""
+}
+
+func (o *Object) ownsNamespace(ns string) bool {
+	if contains(globalNamespaces, ns) {
+		return true
+	}
+	return o.context != nil && ns == o.context.AppNamespace
+}
+
+func (o *Object) appNamespaceNote() string {
+	if o.context == nil {
+		return ""
+	}
+	if o.context.AppNamespace == "" {
+		return fmt.Sprintf(", app %d has no namespace", o.context.AppID)
+	}
+	return fmt.Sprintf(", app %d has the namespace %s",
+		o.context.AppID, o.context.AppNamespace)
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,136 @@
+package og
+
+import (
+	"testing"
+
+	"github.com/daaku/rell/context"
+)
+
+func lintFor(lints []Lint, property string) *Lint {
+	for _, l := range lints {
+		if l.Property == property {
+			return &l
+		}
+	}
+	return nil
+}
+
+func TestValidateValid(t *testing.T) {
+	t.Parallel()
+	o := &Object{Pairs: []Pair{
+		{"og:type", "article"},
+		{"og:title", "foo"},
+		{"og:url", "http://www.fbrell.com/og/article/foo"},
+		{"og:image", "http://www.fbrell.com/public/images/a.jpg"},
+		{"og:image:width", "100"},
+		{"og:image:secure_url", "https://www.fbrell.com/public/images/a.jpg"},
+		{"fb:app_id", "184484190795"},
+	}}
+	if lints := o.Validate(); len(lints) != 0 {
+		t.Fatalf("Was not expecting lint results, found %+v", lints)
+	}
+}
+
+func TestValidateRequired(t *testing.T) {
+	t.Parallel()
+	o := &Object{Pairs: []Pair{{"og:title", "foo"}}}
+	lints := o.Validate()
+	for _, key := range []string{"og:type", "og:url", "og:image"} {
+		l := lintFor(lints, key)
+		if l == nil || l.Level != LintError {
+			t.Fatalf("Did not find expected error for missing %s in %+v", key, lints)
+		}
+	}
+}
+
+func TestValidateStructured(t *testing.T) {
+	t.Parallel()
+	o := &Object{Pairs: []Pair{
+		{"og:type", "article"},
+		{"og:title", "foo"},
+		{"og:url", "http://www.fbrell.com/"},
+		{"og:video:width", "100"},
+		{"og:image", "http://www.fbrell.com/a.jpg"},
+		{"og:image:height", "tall"},
+		{"og:image:colour", "red"},
+	}}
+	lints := o.Validate()
+	cases := []struct {
+		property string
+		level    string
+	}{
+		{"og:video:width", LintError},
+		{"og:image:height", LintError},
+		{"og:image:colour", LintWarning},
+	}
+	for _, c := range cases {
+		l := lintFor(lints, c.property)
+		if l == nil || l.Level != c.level {
+			t.Fatalf("Did not find expected %s for %s in %+v", c.level, c.property, lints)
+		}
+	}
+}
+
+func TestValidateURLs(t *testing.T) {
+	t.Parallel()
+	o := &Object{Pairs: []Pair{
+		{"og:type", "article"},
+		{"og:title", "foo"},
+		{"og:url", "/og/article/foo"},
+		{"og:image", "javascript:alert(1)"},
+		{"og:image:secure_url", "http://www.fbrell.com/a.jpg"},
+	}}
+	lints := o.Validate()
+	for _, key := range []string{"og:url", "og:image", "og:image:secure_url"} {
+		if l := lintFor(lints, key); l == nil || l.Level != LintError {
+			t.Fatalf("Did not find expected error for %s in %+v", key, lints)
+		}
+	}
+}
+
+func TestValidateNamespace(t *testing.T) {
+	t.Parallel()
+	pairs := []Pair{
+		{"og:type", "fbrell:recipe"},
+		{"og:title", "foo"},
+		{"og:url", "http://www.fbrell.com/"},
+		{"og:image", "http://www.fbrell.com/a.jpg"},
+		{"fbrell:calories", "100"},
+	}
+	owned := &Object{
+		Pairs:   pairs,
+		context: &context.Context{AppID: 1, AppNamespace: "fbrell"},
+	}
+	if lints := owned.Validate(); len(lints) != 0 {
+		t.Fatalf("Was not expecting lint results, found %+v", lints)
+	}
+	other := &Object{
+		Pairs:   pairs,
+		context: &context.Context{AppID: 2, AppNamespace: "other"},
+	}
+	lints := other.Validate()
+	for _, key := range []string{"og:type", "fbrell:calories"} {
+		if l := lintFor(lints, key); l == nil || l.Level != LintWarning {
+			t.Fatalf("Did not find expected warning for %s in %+v", key, lints)
+		}
+	}
+}
+
+func TestValidateMultiple(t *testing.T) {
+	t.Parallel()
+	o := &Object{Pairs: []Pair{
+		{"og:type", "website"},
+		{"og:type", "article"},
+		{"og:title", "foo"},
+		{"og:url", "http://www.fbrell.com/"},
+		{"og:image", "http://www.fbrell.com/a.jpg"},
+		{"og:image", "http://www.fbrell.com/b.jpg"},
+	}}
+	lints := o.Validate()
+	if l := lintFor(lints, "og:type"); l == nil || l.Level != LintWarning {
+		t.Fatalf("Did not find expected warning for og:type in %+v", lints)
+	}
+	if l := lintFor(lints, "og:image"); l != nil {
+		t.Fatalf("Was not expecting a lint for og:image arrays, found %+v", l)
+	}
+}
@@ -0,0 +1,423 @@
+package viewog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/daaku/go.errcode"
+	"github.com/daaku/go.h"
+	"github.com/daaku/go.static"
+
+	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/og"
+	"github.com/daaku/rell/view"
+)
+
+const BuilderPath = "/og-builder/"
+
+// Properties offered for autocomplete in the builder.
+var commonProperties = []string{
+	"og:type",
+	"og:title",
+	"og:url",
+	"og:image",
+	"og:image:url",
+	"og:image:secure_url",
+	"og:image:type",
+	"og:image:width",
+	"og:image:height",
+	"og:description",
+	"og:site_name",
+	"og:locale",
+	"og:determiner",
+	"og:video",
+	"og:video:secure_url",
+	"og:video:type",
+	"og:video:width",
+	"og:video:height",
+	"og:audio",
+	"og:audio:secure_url",
+	"og:audio:type",
+	"fb:app_id",
+	"fb:admins",
+	"article:author",
+	"article:published_time",
+	"article:section",
+	"article:tag",
+}
+
+var builderPageConfig = &view.PageConfig{
+	GA:     view.DefaultPageConfig.GA,
+	Style:  view.DefaultPageConfig.Style,
+	Script: append(append([]string{}, view.DefaultPageConfig.Script...), "js/og-builder.js"),
+}
+
+// Handles /og-builder/ requests.
+func (a *Handler) Builder(w http.ResponseWriter, r *http.Request) {
+	context, err := a.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	var pairs []og.Pair
+	var noDefault []string
+	if raw := r.FormValue("url"); raw != "" {
+		pairs, noDefault, err = pairsFromURL(raw)
+		if err != nil {
+			view.Error(w, r, a.Static, err)
+			return
+		}
+	} else {
+		keys, values, nulls := r.Form["key"], r.Form["value"], r.Form["null"]
+		for i, key := range keys {
+			key = strings.TrimSpace(key)
+			if key == "" || i >= len(values) {
+				continue
+			}
+			// Rows without a default stay that way until given a value.
+			if i < len(nulls) && nulls[i] != "" && values[i] == "" {
+				noDefault = append(noDefault, key)
+				continue
+			}
+			pairs = append(pairs, og.Pair{Key: key, Value: values[i]})
+		}
+	}
+	h.WriteResponse(w, r, &builderPage{
+		Context:   context,
+		Static:    a.Static,
+		Pairs:     pairs,
+		NoDefault: noDefault,
+	})
+}
+
+// Parse the pairs from an existing /og/ or /rog/ URL, without the generated
+// defaults. Also returns the keys of the /rog/ rows without a default.
+func pairsFromURL(raw string) ([]og.Pair, []string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, nil, errcode.New(http.StatusBadRequest, "Invalid URL: %s", raw)
+	}
+	parts := strings.Split(u.Path, "/")
+	switch {
+	case len(parts) == 3 && parts[1] == "rog":
+		pairs, noDefault, err := og.DecodeBase64(parts[2])
+		if err != nil {
+			return nil, nil, errcode.New(http.StatusBadRequest, "%s", err)
+		}
+		return pairs, noDefault, nil
+	case len(parts) > 1 && len(parts) < 5 && parts[1] == "og":
+		// Same as the /og/ handler, but only the properties.
+		var pairs []og.Pair
+		for _, pair := range pathPairs(parts, og.ParseQuery(u.RawQuery)) {
+			if strings.Contains(pair.Key, ":") {
+				pairs = append(pairs, pair)
+			}
+		}
+		return pairs, nil, nil
+	}
+	return nil, nil, errcode.New(
+		http.StatusBadRequest, "Not an /og/ or /rog/ URL: %s", raw)
+}
+
+// The JSON [key, value] rows for the pairs, as encoded in /rog/ URLs, followed
+// by [key, null] rows for the keys without a default.
+func rowsJSON(pairs []og.Pair, noDefault []string) []byte {
+	rows := make([][]interface{}, 0, len(pairs)+len(noDefault))
+	for _, pair := range pairs {
+		rows = append(rows, []interface{}{pair.Key, pair.Value})
+	}
+	for _, key := range noDefault {
+		rows = append(rows, []interface{}{key, nil})
+	}
+	j, _ := json.Marshal(rows)
+	return j
+}
+
+// The /rog/ URL for the pairs and the keys without a default.
+func rogURL(c *context.Context, pairs []og.Pair, noDefault []string) string {
+	b64 := strings.TrimRight(
+		base64.URLEncoding.EncodeToString(rowsJSON(pairs, noDefault)), "=")
+	return c.AbsoluteURL("/rog/" + b64).String()
+}
+
+// The human readable /og/ URL for the pairs. The first og:type and og:title
+// go in the path when they can, and the rest of the pairs in order in the
+// query string after the context values.
+func ogURL(c *context.Context, pairs []og.Pair) string {
+	typeAt, titleAt := -1, -1
+	for i, pair := range pairs {
+		if pair.Key == "og:type" && typeAt == -1 {
+			typeAt = i
+		}
+		if pair.Key == "og:title" && titleAt == -1 {
+			titleAt = i
+		}
+	}
+	path := "/og/"
+	if typeAt != -1 && inPath(pairs[typeAt].Value) {
+		path += pairs[typeAt].Value
+		if titleAt != -1 && inPath(pairs[titleAt].Value) {
+			path += "/" + pairs[titleAt].Value
+		} else {
+			titleAt = -1
+		}
+	} else {
+		typeAt, titleAt = -1, -1
+	}
+	var rest []og.Pair
+	for i, pair := range pairs {
+		if i != typeAt && i != titleAt {
+			rest = append(rest, pair)
+		}
+	}
+	var query []string
+	if values := c.Values().Encode(); values != "" {
+		query = append(query, values)
+	}
+	if len(rest) > 0 {
+		query = append(query, og.EncodeQuery(rest))
+	}
+	u := c.AbsoluteURL(path)
+	u.RawQuery = strings.Join(query, "&")
+	return u.String()
+}
+
+// Whether the value can go in the /og/ path, which is split on "/" after
+// unescaping and where "." and ".." would be cleaned away. The path is
+// escaped when the URL is built.
+func inPath(value string) bool {
+	return value != "" && value != "." && value != ".." &&
+		!strings.ContainsAny(value, "/?")
+}
+
+// The <meta> tags for the pairs as text.
+func metaTags(pairs []og.Pair) string {
+	var lines []string
+	for _, pair := range pairs {
+		lines = append(lines, `<meta property="`+html.EscapeString(pair.Key)+
+			`" content="`+html.EscapeString(pair.Value)+`">`)
+	}
+	return strings.Join(lines, "\n")
+}
+
+type builderPage struct {
+	Context   *context.Context
+	Static    *static.Handler
+	Pairs     []og.Pair
+	NoDefault []string
+}
+
+func (p *builderPage) HTML() (h.HTML, error) {
+	properties, err := json.Marshal(commonProperties)
+	if err != nil {
+		return nil, err
+	}
+	rows := &h.Frag{}
+	for _, pair := range p.Pairs {
+		rows.Append(&builderRow{Pair: pair})
+	}
+	for _, key := range p.NoDefault {
+		rows.Append(&builderRow{Pair: og.Pair{Key: key}, NoDefault: true})
+	}
+	// An empty row to add to, and to clone from in the JS.
+	rows.Append(&builderRow{})
+	return &view.Page{
+		Config:  builderPageConfig,
+		Context: p.Context,
+		Static:  p.Static,
+		Title:   "OG Builder",
+		Class:   "og-builder",
+		Body: &h.Div{
+			Class: "container",
+			Inner: &h.Div{
+				Class: "row",
+				Inner: &h.Div{
+					Class: "span12",
+					Inner: &h.Frag{
+						&h.H1{Inner: h.String("OG Builder")},
+						&h.Form{
+							Action: BuilderPath,
+							Method: "get",
+							Class:  "form-inline",
+							Inner: &h.Frag{
+								h.HiddenInputs(p.Context.Values()),
+								&h.Input{
+									Type:        "text",
+									Name:        "url",
+									Class:       "input-xxlarge",
+									Placeholder: "Load an existing /og/ or /rog/ URL",
+								},
+								h.String(" "),
+								&h.Button{
+									Type:  "submit",
+									Class: "btn",
+									Inner: h.String("Load"),
+								},
+							},
+						},
+						&h.Form{
+							Action: BuilderPath,
+							Method: "get",
+							Inner: &h.Frag{
+								h.HiddenInputs(p.Context.Values()),
+								&h.Input{
+									Type:  "hidden",
+									ID:    "og-builder-properties",
+									Value: string(properties),
+								},
+								&h.Table{
+									Class: "table table-condensed og-builder-pairs",
+									Inner: &h.Frag{
+										&h.Thead{
+											Inner: &h.Tr{
+												Inner: &h.Frag{
+													&h.Th{Inner: h.String("Property")},
+													&h.Th{Inner: h.String("Content")},
+													&h.Th{},
+												},
+											},
+										},
+										&h.Tbody{Inner: rows},
+									},
+								},
+								&h.Div{
+									Class: "form-actions",
+									Inner: &h.Frag{
+										&h.Button{
+											Type:  "button",
+											Class: "btn og-builder-add",
+											Inner: &h.Frag{
+												&h.I{Class: "icon-plus"},
+												h.String(" Add"),
+											},
+										},
+										h.String(" "),
+										&h.Button{
+											Type:  "submit",
+											Class: "btn btn-primary",
+											Inner: &h.Frag{
+												&h.I{Class: "icon-refresh icon-white"},
+												h.String(" Preview"),
+											},
+										},
+									},
+								},
+							},
+						},
+						&builderPreview{
+							Context:   p.Context,
+							Pairs:     p.Pairs,
+							NoDefault: p.NoDefault,
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+// A row in the builder. Rows without a default have no value, and keep a
+// flag in the form so they round trip until given one.
+type builderRow struct {
+	Pair      og.Pair
+	NoDefault bool
+}
+
+func (r *builderRow) HTML() (h.HTML, error) {
+	value := &h.Input{
+		Type:  "text",
+		Name:  "value",
+		Value: r.Pair.Value,
+		Class: "input-xxlarge",
+	}
+	null := ""
+	if r.NoDefault {
+		value.Placeholder = "No default"
+		null = "1"
+	}
+	return &h.Tr{
+		Inner: &h.Frag{
+			&h.Td{
+				Inner: &h.Input{
+					Type:  "text",
+					Name:  "key",
+					Value: r.Pair.Key,
+					Class: "input-large og-builder-key",
+				},
+			},
+			&h.Td{
+				Inner: &h.Frag{
+					value,
+					&h.Input{Type: "hidden", Name: "null", Value: null},
+				},
+			},
+			&h.Td{
+				Inner: &h.Frag{
+					&h.Button{
+						Type:  "button",
+						Class: "btn btn-mini og-builder-up",
+						Inner: &h.I{Class: "icon-arrow-up"},
+					},
+					h.String(" "),
+					&h.Button{
+						Type:  "button",
+						Class: "btn btn-mini og-builder-down",
+						Inner: &h.I{Class: "icon-arrow-down"},
+					},
+					h.String(" "),
+					&h.Button{
+						Type:  "button",
+						Class: "btn btn-mini og-builder-remove",
+						Inner: &h.I{Class: "icon-remove"},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+type builderPreview struct {
+	Context   *context.Context
+	Pairs     []og.Pair
+	NoDefault []string
+}
+
+// Only the /rog/ URL can leave out defaults, the /og/ URL generates them.
+func (p *builderPreview) HTML() (h.HTML, error) {
+	if len(p.Pairs) == 0 && len(p.NoDefault) == 0 {
+		return nil, nil
+	}
+	ogLink := ogURL(p.Context, p.Pairs)
+	rogLink := rogURL(p.Context, p.Pairs, p.NoDefault)
+	return &h.Frag{
+		&h.H2{Inner: h.String("Meta Tags")},
+		&h.Textarea{
+			Class: "input-block-level og-builder-meta",
+			Inner: h.String(metaTags(p.Pairs)),
+		},
+		&h.H2{Inner: h.String("URLs")},
+		&h.Table{
+			Class: "table table-bordered og-info",
+			Inner: &h.Tbody{
+				Inner: &h.Frag{
+					&h.Tr{
+						Inner: &h.Frag{
+							&h.Th{Inner: h.String("/og/")},
+							&h.Td{Inner: &h.A{HREF: ogLink, Inner: h.String(ogLink)}},
+						},
+					},
+					&h.Tr{
+						Inner: &h.Frag{
+							&h.Th{Inner: h.String("/rog/")},
+							&h.Td{Inner: &h.A{HREF: rogLink, Inner: h.String(rogLink)}},
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,122 @@
+package viewog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daaku/go.fbapp"
+
+	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/og"
+)
+
+var builderPairs = []og.Pair{
+	{Key: "og:type", Value: "article"},
+	{Key: "og:title", Value: "foo bar"},
+	{Key: "og:image", Value: "http://www.fbrell.com/a.jpg"},
+	{Key: "og:image:width", Value: "100"},
+	{Key: "og:image", Value: "http://www.fbrell.com/b.jpg"},
+	{Key: "og:description", Value: "a & b"},
+}
+
+func defaultContext() *context.Context {
+	parser := &context.Parser{App: fbapp.New(184484190795, "", "fbrell")}
+	return parser.Default()
+}
+
+func TestBuilderRoundTripRog(t *testing.T) {
+	t.Parallel()
+	noDefault := []string{"fb:app_id"}
+	u := rogURL(defaultContext(), builderPairs, noDefault)
+	pairs, actualNoDefault, err := pairsFromURL(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pairs, builderPairs) {
+		t.Fatalf("Did not find expected pairs from %s instead found %v", u, pairs)
+	}
+	if !reflect.DeepEqual(actualNoDefault, noDefault) {
+		t.Fatalf("Did not find expected keys without a default from %s instead found %v",
+			u, actualNoDefault)
+	}
+}
+
+func TestBuilderRoundTripOg(t *testing.T) {
+	t.Parallel()
+	u := ogURL(defaultContext(), builderPairs)
+	pairs, _, err := pairsFromURL(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(pairs, builderPairs) {
+		t.Fatalf("Did not find expected pairs from %s instead found %v", u, pairs)
+	}
+}
+
+func TestBuilderOgPath(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		Type, Title string
+		Path        string
+	}{
+		{"article", "a b?c", "/og/article"},
+		{"article", "a/b", "/og/article"},
+		{"article", "..", "/og/article"},
+		{"a/b", "title", "/og/"},
+		{"article", "100% & more", "/og/article/100%25%20&%20more"},
+	}
+	for _, c := range cases {
+		expected := []og.Pair{
+			{Key: "og:type", Value: c.Type},
+			{Key: "og:title", Value: c.Title},
+		}
+		u := ogURL(defaultContext(), expected)
+		if !strings.HasPrefix(u, "http://www.fbrell.com"+c.Path+"?") &&
+			u != "http://www.fbrell.com"+c.Path {
+			t.Fatalf("Did not find expected path %s instead found %s", c.Path, u)
+		}
+		pairs, _, err := pairsFromURL(u)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(pairs, expected) {
+			t.Fatalf("Did not find expected pairs from %s instead found %v", u, pairs)
+		}
+	}
+}
+
+func TestBuilderPathReplacesQuery(t *testing.T) {
+	t.Parallel()
+	const u = "/og/article/foo?og%3Atype=book&og%3Aimage=a.jpg&og%3Atitle=bar&appid=1"
+	pairs, _, err := pairsFromURL(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []og.Pair{
+		{Key: "og:type", Value: "article"},
+		{Key: "og:title", Value: "foo"},
+		{Key: "og:image", Value: "a.jpg"},
+	}
+	if !reflect.DeepEqual(pairs, expected) {
+		t.Fatalf("Did not find expected pairs from %s instead found %v", u, pairs)
+	}
+}
+
+func TestBuilderInvalidURL(t *testing.T) {
+	t.Parallel()
+	for _, u := range []string{"/examples/", "/rog/!!!", "/og/a/b/c/d"} {
+		if _, _, err := pairsFromURL(u); err == nil {
+			t.Fatalf("Was expecting an error for %s", u)
+		}
+	}
+}
+
+func TestMetaTags(t *testing.T) {
+	t.Parallel()
+	actual := metaTags([]og.Pair{{Key: "og:title", Value: `"quoted" & more`}})
+	const expected = `<meta property="og:title" content="&#34;quoted&#34; &amp; more">`
+	if actual != expected {
+		t.Fatalf("Did not find expected %s instead found %s", expected, actual)
+	}
+}
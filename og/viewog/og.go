@@ -3,6 +3,8 @@ package viewog
 
 import (
 	"fmt"
+	"io"
+	"io/ioutil"
 	"net/http"
 	"strconv"
 	"strings"
@@ -16,14 +18,26 @@ import (
 
 	"github.com/daaku/rell/context"
 	"github.com/daaku/rell/og"
+	"github.com/daaku/rell/ratelimit"
 	"github.com/daaku/rell/view"
 )
 
+const (
+	StoredPath = "/sog/"
+
+	// Larger than the stored limit, which is checked on the canonical form.
+	maxBodySize = 65536
+)
+
+var errTooManySaves = errcode.New(
+	ratelimit.StatusTooManyRequests, "Too many saved objects, try again later.")
+
 type Handler struct {
 	ContextParser *context.Parser
 	Static        *static.Handler
 	Stats         stats.Backend
 	ObjectParser  *og.Parser
+	SaveLimiter   *ratelimit.Limiter
 }
 
 // Handles /og/ requests.
@@ -33,26 +47,43 @@ func (a *Handler) Values(w http.ResponseWriter, r *http.Request) {
 		view.Error(w, r, a.Static, err)
 		return
 	}
-	values := r.URL.Query()
 	parts := strings.Split(r.URL.Path, "/")
 	if len(parts) > 4 {
 		view.Error(w, r, a.Static, errcode.New(
 			http.StatusNotFound, "Invalid URL: %s", r.URL.Path))
 		return
 	}
-	if len(parts) > 2 {
-		values.Set("og:type", parts[2])
-	}
-	if len(parts) > 3 {
-		values.Set("og:title", parts[3])
-	}
-	object, err := a.ObjectParser.FromValues(context, values)
+	object, err := a.ObjectParser.FromPairs(
+		context, pathPairs(parts, og.ParseQuery(r.URL.RawQuery)))
 	if err != nil {
 		view.Error(w, r, a.Static, err)
 		return
 	}
 	a.Stats.Count("viewed og", 1)
-	h.WriteResponse(w, r, renderObject(context, a.Static, object))
+	a.respond(w, r, context, object)
+}
+
+// The og:type and og:title from the path parts replace those in the query
+// pairs, and the order of the remaining pairs is preserved.
+func pathPairs(parts []string, query []og.Pair) []og.Pair {
+	var pairs []og.Pair
+	if len(parts) > 2 && parts[2] != "" {
+		pairs = append(pairs, og.Pair{Key: "og:type", Value: parts[2]})
+	}
+	if len(parts) > 3 && parts[3] != "" {
+		pairs = append(pairs, og.Pair{Key: "og:title", Value: parts[3]})
+	}
+	n := len(pairs)
+Outer:
+	for _, pair := range query {
+		for _, p := range pairs[:n] {
+			if pair.Key == p.Key {
+				continue Outer
+			}
+		}
+		pairs = append(pairs, pair)
+	}
+	return pairs
 }
 
 // Handles /rog/* requests.
@@ -74,6 +105,79 @@ func (a *Handler) Base64(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	a.Stats.Count("viewed rog", 1)
+	a.respond(w, r, context, object)
+}
+
+// Handles /sog/* requests. A POST to /sog/ stores the JSON definition in the
+// body, or in the "object" form value, and redirects to the stored object.
+func (a *Handler) Stored(w http.ResponseWriter, r *http.Request) {
+	context, err := a.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	if r.Method == "POST" && r.URL.Path == StoredPath {
+		a.save(w, r, context)
+		return
+	}
+	parts := strings.Split(r.URL.Path, "/")
+	if len(parts) != 3 {
+		view.Error(w, r, a.Static, errcode.New(
+			http.StatusNotFound, "Invalid URL: %s", r.URL.Path))
+		return
+	}
+	object, err := a.ObjectParser.FromID(context, parts[2])
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	a.Stats.Count("viewed sog", 1)
+	a.respond(w, r, context, object)
+}
+
+func (a *Handler) save(w http.ResponseWriter, r *http.Request, context *context.Context) {
+	var definition []byte
+	if object := r.FormValue("object"); object != "" {
+		definition = []byte(object)
+	} else {
+		body, err := ioutil.ReadAll(io.LimitReader(r.Body, maxBodySize))
+		if err != nil {
+			view.Error(w, r, a.Static, err)
+			return
+		}
+		definition = body
+	}
+	if !a.SaveLimiter.Allow("ip:" + ratelimit.ClientIP(r)) {
+		a.Stats.Count(StoredPath+" throttled by ip", 1)
+		view.Error(w, r, a.Static, errTooManySaves)
+		return
+	}
+	id, err := a.ObjectParser.Store.Save(definition)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	a.Stats.Count("saved og", 1)
+	u := context.AbsoluteURL(StoredPath + id).String()
+	if view.WantsJSON(r) {
+		view.JSON(w, r, a.Static, map[string]string{"id": id, "url": u})
+		return
+	}
+	http.Redirect(w, r, u, 302)
+}
+
+// Render the object, or its pairs and lint results as JSON.
+func (a *Handler) respond(w http.ResponseWriter, r *http.Request, context *context.Context, object *og.Object) {
+	if view.WantsJSON(r) {
+		view.JSON(w, r, a.Static, map[string]interface{}{
+			"pairs":  object.Pairs,
+			"images": object.Images(),
+			"videos": object.Videos(),
+			"audios": object.Audios(),
+			"lint":   object.Validate(),
+		})
+		return
+	}
 	h.WriteResponse(w, r, renderObject(context, a.Static, object))
 }
 
@@ -160,6 +264,43 @@ func renderMetaTable(o *og.Object) h.HTML {
 	}
 }
 
+// Renders the validation results for the object.
+func renderLint(o *og.Object) h.HTML {
+	lints := o.Validate()
+	if len(lints) == 0 {
+		return &h.Div{
+			Class: "alert alert-success",
+			Inner: h.String("No problems found."),
+		}
+	}
+	frag := &h.Frag{}
+	for _, lint := range lints {
+		frag.Append(&h.Tr{
+			Class: lint.Level,
+			Inner: &h.Frag{
+				&h.Td{Inner: h.String(lint.Level)},
+				&h.Td{Inner: h.String(lint.Property)},
+				&h.Td{Inner: h.String(lint.Message)},
+			},
+		})
+	}
+	return &h.Table{
+		Class: "table table-bordered og-lint",
+		Inner: &h.Frag{
+			&h.Thead{
+				Inner: &h.Tr{
+					Inner: &h.Frag{
+						&h.Th{Inner: h.String("Level")},
+						&h.Th{Inner: h.String("Property")},
+						&h.Th{Inner: h.String("Problem")},
+					},
+				},
+			},
+			&h.Tbody{Inner: frag},
+		},
+	}
+}
+
 // Render a document for the Object.
 func renderObject(context *context.Context, s *static.Handler, o *og.Object) h.HTML {
 	var title, header h.HTML
@@ -225,6 +366,7 @@ func renderObject(context *context.Context, s *static.Handler, o *og.Object) h.H
 							&h.Div{
 								Class: "span6",
 								Inner: &h.Frag{
+									renderLint(o),
 									renderMetaTable(o),
 									&h.Iframe{
 										Class: "like",
@@ -0,0 +1,32 @@
+package viewog
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/daaku/rell/og"
+)
+
+func TestPathPairs(t *testing.T) {
+	t.Parallel()
+	query := []og.Pair{
+		{Key: "og:image", Value: "a.jpg"},
+		{Key: "og:type", Value: "book"},
+		{Key: "og:image:width", Value: "100"},
+	}
+	expected := []og.Pair{
+		{Key: "og:type", Value: "article"},
+		{Key: "og:title", Value: "foo"},
+		{Key: "og:image", Value: "a.jpg"},
+		{Key: "og:image:width", Value: "100"},
+	}
+	pairs := pathPairs(strings.Split("/og/article/foo", "/"), query)
+	if !reflect.DeepEqual(pairs, expected) {
+		t.Fatalf("Did not find expected pairs %v instead found %v", expected, pairs)
+	}
+	pairs = pathPairs(strings.Split("/og", "/"), query)
+	if !reflect.DeepEqual(pairs, query) {
+		t.Fatalf("Did not find expected pairs %v instead found %v", query, pairs)
+	}
+}
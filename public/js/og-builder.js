@@ -0,0 +1,33 @@
+// Row editing and property autocomplete for the OG builder.
+$(function() {
+  var properties = JSON.parse($('#og-builder-properties').val() || '[]')
+    , tbody = $('.og-builder-pairs tbody')
+    , template = tbody.find('tr:last').clone()
+
+  function setupRow(row) {
+    row.find('.og-builder-key').typeahead({ source: properties })
+    return row
+  }
+
+  tbody.find('tr').each(function() { setupRow($(this)) })
+
+  $('.og-builder-add').click(function() {
+    var row = setupRow(template.clone())
+    tbody.append(row)
+    row.find('.og-builder-key').focus()
+  })
+
+  tbody.on('click', '.og-builder-remove', function() {
+    $(this).closest('tr').remove()
+  })
+
+  tbody.on('click', '.og-builder-up', function() {
+    var row = $(this).closest('tr')
+    row.prev().before(row)
+  })
+
+  tbody.on('click', '.og-builder-down', function() {
+    var row = $(this).closest('tr')
+    row.next().after(row)
+  })
+})
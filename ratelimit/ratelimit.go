@@ -0,0 +1,114 @@
+// Package ratelimit provides an in-memory token bucket rate limiter
+// keyed by arbitrary strings like browser IDs or IP addresses.
+package ratelimit
+
+import (
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/daaku/go.trustforward"
+)
+
+// The HTTP status for a denied request, which net/http doesn't define.
+const StatusTooManyRequests = 429
+
+// The client IP address to use as a key, without the port.
+func ClientIP(r *http.Request) string {
+	remote := trustforward.Remote(r)
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
+
+// A Limiter allows Count events per Interval for each key, with bursts
+// of up to Count events. A nil Limiter or one with a zero Count or
+// Interval allows everything.
+type Limiter struct {
+	Count    int
+	Interval time.Duration
+
+	mutex     sync.Mutex
+	buckets   map[string]*bucket
+	lastPrune time.Time
+	now       func() time.Time
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Check if an event for all the keys is allowed, consuming a token
+// from each if it is. Nothing is consumed if any key is denied.
+func (l *Limiter) Allow(keys ...string) bool {
+	return l.Check(keys...) == ""
+}
+
+// Like Allow, but returns the first key that was denied, or an empty
+// string if the event was allowed.
+func (l *Limiter) Check(keys ...string) string {
+	if l == nil || l.Count <= 0 || l.Interval <= 0 {
+		return ""
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	now := l.clock()
+	if l.buckets == nil {
+		l.buckets = make(map[string]*bucket)
+		l.lastPrune = now
+	}
+	l.prune(now)
+
+	buckets := make([]*bucket, len(keys))
+	for i, key := range keys {
+		b := l.buckets[key]
+		if b == nil {
+			b = &bucket{tokens: float64(l.Count), last: now}
+			l.buckets[key] = b
+		}
+		b.tokens = l.refill(b, now)
+		b.last = now
+		if b.tokens < 1 {
+			return key
+		}
+		buckets[i] = b
+	}
+	for _, b := range buckets {
+		b.tokens--
+	}
+	return ""
+}
+
+// The number of tokens in the bucket at the given time.
+func (l *Limiter) refill(b *bucket, now time.Time) float64 {
+	elapsed := now.Sub(b.last)
+	tokens := b.tokens + float64(l.Count)*float64(elapsed)/float64(l.Interval)
+	if tokens > float64(l.Count) {
+		tokens = float64(l.Count)
+	}
+	return tokens
+}
+
+// Full buckets are indistinguishable from missing ones, so we
+// periodically drop them to keep memory bounded.
+func (l *Limiter) prune(now time.Time) {
+	if now.Sub(l.lastPrune) < l.Interval {
+		return
+	}
+	l.lastPrune = now
+	for key, b := range l.buckets {
+		if l.refill(b, now) >= float64(l.Count) {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func (l *Limiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
@@ -0,0 +1,84 @@
+package ratelimit
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestAllow(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(0, 0)
+	l := &Limiter{
+		Count:    2,
+		Interval: time.Minute,
+		now:      func() time.Time { return now },
+	}
+	if !l.Allow("a") || !l.Allow("a") {
+		t.Fatal("Was expecting the first two events to be allowed.")
+	}
+	if l.Allow("a") {
+		t.Fatal("Was expecting the third event to be denied.")
+	}
+	if !l.Allow("b") {
+		t.Fatal("Was expecting a different key to be allowed.")
+	}
+	now = now.Add(30 * time.Second)
+	if !l.Allow("a") {
+		t.Fatal("Was expecting an event to be allowed after refill.")
+	}
+	if l.Allow("a") {
+		t.Fatal("Was expecting the event after refill to be denied.")
+	}
+}
+
+func TestPrune(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(0, 0)
+	l := &Limiter{
+		Count:    1,
+		Interval: time.Minute,
+		now:      func() time.Time { return now },
+	}
+	l.Allow("a")
+	now = now.Add(2 * time.Minute)
+	l.Allow("b")
+	if _, ok := l.buckets["a"]; ok {
+		t.Fatal("Was expecting the full bucket to be pruned.")
+	}
+}
+
+func TestDisabled(t *testing.T) {
+	t.Parallel()
+	var l *Limiter
+	if !l.Allow("a") {
+		t.Fatal("Was expecting a nil Limiter to allow everything.")
+	}
+}
+
+func TestCheckMultipleKeys(t *testing.T) {
+	t.Parallel()
+	now := time.Unix(0, 0)
+	l := &Limiter{
+		Count:    1,
+		Interval: time.Minute,
+		now:      func() time.Time { return now },
+	}
+	if !l.Allow("ip") {
+		t.Fatal("Was expecting the first event to be allowed.")
+	}
+	if key := l.Check("browser", "ip"); key != "ip" {
+		t.Fatalf("Did not find expected denied key ip, found %q", key)
+	}
+	if !l.Allow("browser") {
+		t.Fatal("Was expecting a denied event to not use the browser quota.")
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	t.Parallel()
+	r := &http.Request{RemoteAddr: "10.0.0.1:1234", Header: http.Header{}}
+	if ip := ClientIP(r); ip != "10.0.0.1" {
+		t.Fatalf("Did not find expected IP instead found %s", ip)
+	}
+}
@@ -0,0 +1,151 @@
+// Package requestlog assigns an ID to every request and emits
+// structured JSON access logs. Handlers further down the chain can
+// annotate the log entry for the current request using Handler.Set.
+package requestlog
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/daaku/go.trustforward"
+)
+
+// The header carrying the request ID, set on both the request and the
+// response.
+const Header = "X-Request-Id"
+
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// Wraps a Handler to assign request IDs and log requests. The logged
+// bytes are those written to the wrapped ResponseWriter, so they are the
+// compressed size if compression happens inside this Handler.
+type Handler struct {
+	Handler http.Handler
+	Logger  Logger // nil uses the standard logger
+
+	mutex   sync.Mutex
+	entries map[string]map[string]interface{} // In flight, by request ID.
+}
+
+// Uses the standard logger.
+type stdLogger struct{}
+
+func (stdLogger) Printf(format string, v ...interface{}) {
+	log.Printf(format, v...)
+}
+
+// Get the ID for the request. Empty if the request did not pass
+// through a Handler.
+func ID(r *http.Request) string {
+	return r.Header.Get(Header)
+}
+
+// Annotate the access log entry for the request with the given key and
+// value. This does nothing if the request is not being served by this
+// Handler, or if the Handler is nil.
+func (h *Handler) Set(r *http.Request, key string, value interface{}) {
+	if h == nil {
+		return
+	}
+	id := ID(r)
+	if id == "" {
+		return
+	}
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+	if entry, ok := h.entries[id]; ok {
+		entry[key] = value
+	}
+}
+
+func (h *Handler) logger() Logger {
+	if h.Logger == nil {
+		return stdLogger{}
+	}
+	return h.Logger
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	id := newID()
+	r.Header.Set(Header, id)
+	w.Header().Set(Header, id)
+
+	entry := map[string]interface{}{
+		"time":      start.UTC().Format(time.RFC3339Nano),
+		"requestID": id,
+		"method":    r.Method,
+		"path":      r.URL.Path,
+		"remote":    trustforward.Remote(r),
+	}
+	h.mutex.Lock()
+	if h.entries == nil {
+		h.entries = make(map[string]map[string]interface{})
+	}
+	h.entries[id] = entry
+	h.mutex.Unlock()
+
+	sw := &statusWriter{ResponseWriter: w}
+	defer func() {
+		h.mutex.Lock()
+		delete(h.entries, id)
+		h.mutex.Unlock()
+		if sw.status == 0 {
+			sw.status = http.StatusOK
+		}
+		entry["status"] = sw.status
+		entry["bytes"] = sw.bytes
+		entry["latencyMS"] = float64(time.Since(start)) / float64(time.Millisecond)
+		out, err := json.Marshal(entry)
+		if err != nil {
+			h.logger().Printf("Failed to encode access log entry: %s", err)
+			return
+		}
+		h.logger().Printf("%s", out)
+	}()
+	h.Handler.ServeHTTP(sw, r)
+}
+
+// Records the status and size of the response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+func (w *statusWriter) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		log.Panicf("failed to generate request ID: %s", err)
+	}
+	return hex.EncodeToString(b)
+}
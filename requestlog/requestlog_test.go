@@ -0,0 +1,71 @@
+package requestlog_test
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/daaku/rell/requestlog"
+)
+
+type logger struct {
+	lines []string
+}
+
+func (l *logger) Printf(format string, v ...interface{}) {
+	l.lines = append(l.lines, fmt.Sprintf(format, v...))
+}
+
+func TestHandler(t *testing.T) {
+	t.Parallel()
+	var seenID string
+	l := &logger{}
+	h := &requestlog.Handler{Logger: l}
+	h.Handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		seenID = requestlog.ID(r)
+		h.Set(r, "viewMode", "canvas")
+		http.NotFound(w, r)
+	})
+	req, err := http.NewRequest("GET", "http://www.fbrell.com/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+
+	if seenID == "" || w.Header().Get(requestlog.Header) != seenID {
+		t.Fatalf("Did not find expected request ID %q in response headers %v",
+			seenID, w.Header())
+	}
+	if len(l.lines) != 1 {
+		t.Fatalf("Was expecting 1 log line instead found %v", l.lines)
+	}
+	var entry map[string]interface{}
+	if err := json.Unmarshal([]byte(l.lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["status"] != float64(http.StatusNotFound) {
+		t.Fatalf("Did not find expected status in %v", entry)
+	}
+	if entry["viewMode"] != "canvas" || entry["requestID"] != seenID {
+		t.Fatalf("Did not find expected annotations in %v", entry)
+	}
+}
+
+func TestHandlerDefaults(t *testing.T) {
+	t.Parallel()
+	var h *requestlog.Handler
+	req, err := http.NewRequest("GET", "http://www.fbrell.com/foo", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.Set(req, "viewMode", "canvas")
+	h = &requestlog.Handler{Handler: http.NotFoundHandler()}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, req)
+	if w.Code != http.StatusNotFound {
+		t.Fatalf("Did not find expected status instead found %d", w.Code)
+	}
+}
@@ -0,0 +1,152 @@
+// Package sr implements decoding, verification and generation of Facebook
+// signed requests, explaining why verification failed where possible.
+package sr
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// The only algorithm Facebook uses for signed requests.
+const Algorithm = "HMAC-SHA256"
+
+var (
+	ErrFormat = errors.New(
+		"A signed request is two base64url encoded parts separated by a period.")
+	ErrSignatureEncoding = errors.New(
+		"The signature part is not valid base64url.")
+	ErrPayloadEncoding = errors.New(
+		"The payload part is not valid base64url.")
+	ErrPayloadJSON = errors.New(
+		"The payload is not a JSON object.")
+	ErrAlgorithm = errors.New(
+		"The payload algorithm must be " + Algorithm + ".")
+	ErrSignature = errors.New("Signature mismatch.")
+)
+
+// A decoded signed request.
+type Decoded struct {
+	Payload   map[string]interface{} `json:"payload"`
+	Signature string                 `json:"signature"` // Hex encoded.
+	Valid     bool                   `json:"valid"`
+	Error     string                 `json:"error,omitempty"`
+}
+
+// Decode and verify a signed request. The Decoded value is returned along with
+// the error when the payload could be decoded but verification failed.
+func Decode(raw string, secret []byte) (*Decoded, error) {
+	parts := strings.Split(strings.TrimSpace(raw), ".")
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, ErrFormat
+	}
+	sig, err := decodeBase64(parts[0])
+	if err != nil {
+		return nil, ErrSignatureEncoding
+	}
+	payload, err := decodeBase64(parts[1])
+	if err != nil {
+		return nil, ErrPayloadEncoding
+	}
+	d := &Decoded{Signature: hex.EncodeToString(sig)}
+	decoder := json.NewDecoder(bytes.NewReader(payload))
+	decoder.UseNumber()
+	if err := decoder.Decode(&d.Payload); err != nil || d.Payload == nil {
+		return nil, ErrPayloadJSON
+	}
+	if algo, _ := d.Payload["algorithm"].(string); strings.ToUpper(algo) != Algorithm {
+		return d, d.fail(ErrAlgorithm)
+	}
+	if !hmac.Equal(sig, mac(secret, parts[1])) {
+		return d, d.fail(ErrSignature)
+	}
+	d.Valid = true
+	return d, nil
+}
+
+func (d *Decoded) fail(err error) error {
+	d.Error = err.Error()
+	return err
+}
+
+// Sign the payload, setting the algorithm and issued_at if necessary.
+func Encode(payload map[string]interface{}, secret []byte, now time.Time) (string, error) {
+	payload["algorithm"] = Algorithm
+	if _, ok := payload["issued_at"]; !ok {
+		payload["issued_at"] = now.Unix()
+	}
+	j, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+	encoded := encodeBase64(j)
+	return encodeBase64(mac(secret, encoded)) + "." + encoded, nil
+}
+
+// The fields used to generate a signed request.
+type Fields struct {
+	UserID     uint64
+	OAuthToken string
+	PageID     uint64
+	PageLiked  bool
+	PageAdmin  bool
+	AppData    string
+	Code       string
+}
+
+// Build the payload for the fields. A page is only included when the PageID
+// is set, which is what distinguishes a Page Tab from a Canvas load.
+func (f *Fields) Payload() map[string]interface{} {
+	payload := map[string]interface{}{
+		"user": map[string]interface{}{
+			"country": "us",
+			"locale":  "en_US",
+		},
+	}
+	if f.UserID != 0 {
+		payload["user_id"] = strconv.FormatUint(f.UserID, 10)
+	}
+	if f.OAuthToken != "" {
+		payload["oauth_token"] = f.OAuthToken
+	}
+	if f.Code != "" {
+		payload["code"] = f.Code
+	}
+	if f.PageID != 0 {
+		payload["page"] = map[string]interface{}{
+			"id":    strconv.FormatUint(f.PageID, 10),
+			"liked": f.PageLiked,
+			"admin": f.PageAdmin,
+		}
+	}
+	if f.AppData != "" {
+		payload["app_data"] = f.AppData
+	}
+	return payload
+}
+
+func mac(secret []byte, encodedPayload string) []byte {
+	m := hmac.New(sha256.New, secret)
+	m.Write([]byte(encodedPayload))
+	return m.Sum(nil)
+}
+
+// Facebook uses base64url without padding.
+func encodeBase64(b []byte) string {
+	return strings.TrimRight(base64.URLEncoding.EncodeToString(b), "=")
+}
+
+func decodeBase64(s string) ([]byte, error) {
+	s = strings.TrimRight(s, "=")
+	if m := len(s) % 4; m != 0 {
+		s += strings.Repeat("=", 4-m)
+	}
+	return base64.URLEncoding.DecodeString(s)
+}
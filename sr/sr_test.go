@@ -0,0 +1,97 @@
+package sr
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	secret = []byte("secret")
+	now    = time.Unix(1300000000, 0)
+)
+
+func TestRoundTrip(t *testing.T) {
+	t.Parallel()
+	f := &Fields{UserID: 4, PageID: 10, PageLiked: true, AppData: "/examples/"}
+	raw, err := Encode(f.Payload(), secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(raw, "=") {
+		t.Fatalf("Did not expect padding in %s", raw)
+	}
+	d, err := Decode(raw, secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !d.Valid {
+		t.Fatal("Was expecting a valid signed request.")
+	}
+	if d.Payload["user_id"] != "4" || d.Payload["app_data"] != "/examples/" {
+		t.Fatalf("Did not find expected payload instead found %v", d.Payload)
+	}
+	if d.Payload["issued_at"] != json.Number("1300000000") {
+		t.Fatalf("Did not find expected issued_at instead found %v", d.Payload["issued_at"])
+	}
+	page, _ := d.Payload["page"].(map[string]interface{})
+	if page["id"] != "10" || page["liked"] != true || page["admin"] != false {
+		t.Fatalf("Did not find expected page instead found %v", page)
+	}
+}
+
+func TestCanvasHasNoPage(t *testing.T) {
+	t.Parallel()
+	f := &Fields{UserID: 4}
+	if _, ok := f.Payload()["page"]; ok {
+		t.Fatal("Did not expect a page for a canvas signed request.")
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	t.Parallel()
+	valid, err := Encode(map[string]interface{}{}, secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parts := strings.Split(valid, ".")
+	badAlgo := parts[0] + "." + encodeBase64([]byte(`{"algorithm":"none"}`))
+
+	cases := []struct {
+		raw      string
+		secret   []byte
+		expected error
+	}{
+		{"", secret, ErrFormat},
+		{"abc", secret, ErrFormat},
+		{"a.b.c", secret, ErrFormat},
+		{"!!!." + parts[1], secret, ErrSignatureEncoding},
+		{parts[0] + ".!!!", secret, ErrPayloadEncoding},
+		{parts[0] + "." + encodeBase64([]byte("[1]")), secret, ErrPayloadJSON},
+		{badAlgo, secret, ErrAlgorithm},
+		{valid, []byte("other"), ErrSignature},
+	}
+	for _, c := range cases {
+		_, err := Decode(c.raw, c.secret)
+		if err != c.expected {
+			t.Fatalf("Did not find expected error %v for %q instead found %v",
+				c.expected, c.raw, err)
+		}
+	}
+}
+
+func TestDecodeReturnsPayloadOnBadSignature(t *testing.T) {
+	t.Parallel()
+	raw, err := Encode(map[string]interface{}{"app_data": "x"}, secret, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d, err := Decode(raw, []byte("other"))
+	if err != ErrSignature {
+		t.Fatalf("Did not find expected ErrSignature instead found %v", err)
+	}
+	if d == nil || d.Valid || d.Payload["app_data"] != "x" || d.Error == "" {
+		t.Fatalf("Did not find expected decoded payload instead found %+v", d)
+	}
+}
@@ -0,0 +1,323 @@
+package viewsr
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daaku/go.errcode"
+	"github.com/daaku/go.h"
+	"github.com/daaku/go.h.ui"
+	"github.com/daaku/go.signedrequest/appdata"
+	"github.com/daaku/go.static"
+
+	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/sr"
+	"github.com/daaku/rell/view"
+)
+
+const (
+	SimulatorPath = "/simulator/"
+	framePath     = SimulatorPath + "frame"
+
+	// The Rell page used for Page Tabs.
+	simulatedPageID = 141929622497380
+)
+
+var errInvalidURL = errcode.New(
+	http.StatusBadRequest, "The URL must be a path on this site.")
+
+// The simulated load, describing what to frame and how to sign it.
+type simulation struct {
+	Mode   string
+	URL    string
+	Fields *sr.Fields
+}
+
+func parseSimulation(r *http.Request) (*simulation, error) {
+	fields, err := parseFields(r)
+	if err != nil {
+		return nil, err
+	}
+	s := &simulation{
+		Mode:   r.FormValue("mode"),
+		URL:    strings.TrimSpace(r.FormValue("url")),
+		Fields: fields,
+	}
+	if s.Mode != context.PageTab {
+		s.Mode = context.Canvas
+	}
+	if s.URL == "" {
+		s.URL = "/"
+	}
+	if !strings.HasPrefix(s.URL, "/") || strings.HasPrefix(s.URL, "//") {
+		return nil, errInvalidURL
+	}
+	if _, err := url.Parse(s.URL); err != nil {
+		return nil, errInvalidURL
+	}
+	return s, nil
+}
+
+// The form target and signed request fields for the simulated load. Canvas
+// loads POST to the URL itself, while Page Tabs POST to the root and carry the
+// URL in app_data, encoded the way the app_data handler expects it.
+func (s *simulation) target() (string, *sr.Fields) {
+	fields := *s.Fields
+	if s.Mode == context.Canvas {
+		fields.PageID = 0
+		return s.URL, &fields
+	}
+	if fields.PageID == 0 {
+		fields.PageID = simulatedPageID
+	}
+	if fields.AppData == "" {
+		u, _ := url.Parse(s.URL) // Validated by parseSimulation.
+		fields.AppData = appdata.Encode(u)
+	}
+	return "/", &fields
+}
+
+// The app the target will verify the signed request with, which like the
+// context is the one named by the appid or client_id parameters.
+func targetAppID(action string, defaultID uint64) uint64 {
+	u, err := url.Parse(action)
+	if err != nil {
+		return defaultID
+	}
+	values := u.Query()
+	raw := values.Get("client_id")
+	if raw == "" {
+		raw = values.Get("appid")
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		return defaultID
+	}
+	return id
+}
+
+func (s *simulation) values() url.Values {
+	values := url.Values{}
+	values.Set("mode", s.Mode)
+	values.Set("url", s.URL)
+	if s.Fields.UserID != 0 {
+		values.Set("user_id", strconv.FormatUint(s.Fields.UserID, 10))
+	}
+	if s.Fields.PageID != 0 {
+		values.Set("page_id", strconv.FormatUint(s.Fields.PageID, 10))
+	}
+	if s.Fields.PageLiked {
+		values.Set("page_liked", "1")
+	}
+	if s.Fields.PageAdmin {
+		values.Set("page_admin", "1")
+	}
+	if s.Fields.AppData != "" {
+		values.Set("app_data", s.Fields.AppData)
+	}
+	if s.Fields.OAuthToken != "" {
+		values.Set("oauth_token", s.Fields.OAuthToken)
+	}
+	return values
+}
+
+// Renders a mock Canvas or Page Tab framing a Rell URL.
+func (a *Handler) Simulator(w http.ResponseWriter, r *http.Request) {
+	if !a.Generate {
+		view.Error(w, r, a.Static, errGenerateDisabled)
+		return
+	}
+	if r.URL.Path == framePath {
+		a.frame(w, r)
+		return
+	}
+	c, err := a.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	s, err := parseSimulation(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	h.WriteResponse(w, r, &simulatorPage{
+		Context:    c,
+		Static:     a.Static,
+		Simulation: s,
+	})
+}
+
+// Renders a form that POSTs the signed request to the target, which is what
+// Facebook does inside the Canvas or Page Tab iframe.
+func (a *Handler) frame(w http.ResponseWriter, r *http.Request) {
+	s, err := parseSimulation(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	action, fields := s.target()
+	app, err := a.Apps.Lookup(targetAppID(action, a.Apps.Default.ID()))
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	signed, err := sr.Encode(fields.Payload(), app.SecretByte(), time.Now())
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	h.WriteResponse(w, r, &h.Document{
+		Inner: &h.Frag{
+			&h.Head{
+				Inner: &h.Meta{Charset: "utf-8"},
+			},
+			&h.Body{
+				Inner: &h.Frag{
+					&h.Form{
+						ID:     "simulator",
+						Action: action,
+						Method: h.Post,
+						Inner: h.HiddenInputs(url.Values{
+							"signed_request": []string{signed},
+						}),
+					},
+					&h.Script{
+						Inner: h.Unsafe("document.getElementById('simulator').submit()"),
+					},
+				},
+			},
+		},
+	})
+}
+
+type simulatorPage struct {
+	Context    *context.Context
+	Static     *static.Handler
+	Simulation *simulation
+}
+
+func (p *simulatorPage) HTML() (h.HTML, error) {
+	s := p.Simulation
+	chrome := "apps.facebook.com/" + p.Context.AppNamespace + s.URL
+	if s.Mode == context.PageTab {
+		_, fields := s.target()
+		chrome = "Page Tab on page " + strconv.FormatUint(fields.PageID, 10)
+	}
+	return &view.Page{
+		Context: p.Context,
+		Static:  p.Static,
+		Title:   "Simulator",
+		Class:   "simulator",
+		Body: &h.Div{
+			Class: "container",
+			Inner: &h.Div{
+				Class: "row",
+				Inner: &h.Frag{
+					&h.Div{
+						Class: "span4",
+						Inner: &simulatorForm{Context: p.Context, Simulation: s},
+					},
+					&h.Div{
+						Class: "span8",
+						Inner: &h.Div{
+							Class: "simulator-chrome simulator-" + s.Mode,
+							Inner: &h.Frag{
+								&h.Div{
+									Class: "simulator-bar",
+									Inner: h.String(chrome),
+								},
+								&h.Iframe{
+									Class: "simulator-frame",
+									Src:   framePath + "?" + s.values().Encode(),
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+type simulatorForm struct {
+	Context    *context.Context
+	Simulation *simulation
+}
+
+func (f *simulatorForm) HTML() (h.HTML, error) {
+	s := f.Simulation
+	return &h.Form{
+		Action: SimulatorPath,
+		Method: "get",
+		Inner: &h.Frag{
+			&h.H2{Inner: h.String("Simulator")},
+			h.HiddenInputs(f.Context.Values()),
+			&ui.TextInput{
+				Label:      h.String("URL"),
+				Name:       "url",
+				Value:      s.URL,
+				InputClass: "input-medium",
+				Tooltip:    "The Rell path to load, for example /examples/.",
+			},
+			&ui.TextInput{
+				Label:      h.String("User ID"),
+				Name:       "user_id",
+				Value:      formatID(s.Fields.UserID),
+				InputClass: "input-medium",
+				Tooltip:    "Leave empty to simulate a user who has not authorized the app.",
+			},
+			&ui.TextInput{
+				Label:      h.String("Page ID"),
+				Name:       "page_id",
+				Value:      formatID(s.Fields.PageID),
+				InputClass: "input-medium",
+				Tooltip:    "Only used for Page Tabs, defaults to the Rell page.",
+			},
+			&ui.TextInput{
+				Label:      h.String("App Data"),
+				Name:       "app_data",
+				Value:      s.Fields.AppData,
+				InputClass: "input-medium",
+				Tooltip:    "Defaults to the URL for Page Tabs.",
+			},
+			&ui.ToggleGroup{
+				Inner: &h.Frag{
+					&ui.ToggleItem{
+						Name:        "page_liked",
+						Checked:     s.Fields.PageLiked,
+						Description: h.String("The user likes the page."),
+					},
+					&ui.ToggleItem{
+						Name:        "page_admin",
+						Checked:     s.Fields.PageAdmin,
+						Description: h.String("The user is a page admin."),
+					},
+				},
+			},
+			&h.Div{
+				Class: "form-actions",
+				Inner: &h.Frag{
+					&h.Button{
+						Type:  "submit",
+						Class: "btn btn-primary",
+						Name:  "mode",
+						Value: context.Canvas,
+						Inner: h.String("Canvas"),
+					},
+					h.String(" "),
+					&h.Button{
+						Type:  "submit",
+						Class: "btn btn-primary",
+						Name:  "mode",
+						Value: context.PageTab,
+						Inner: h.String("Page Tab"),
+					},
+				},
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,121 @@
+package viewsr
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daaku/go.signedrequest/appdata"
+
+	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/sr"
+)
+
+func newRequest(t *testing.T, query string) *http.Request {
+	r, err := http.NewRequest("GET", framePath+"?"+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestSimulationCanvas(t *testing.T) {
+	t.Parallel()
+	s, err := parseSimulation(newRequest(t, "url=/examples/&page_id=10&user_id=4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Mode != context.Canvas {
+		t.Fatalf("Did not find expected mode %s instead found %s", context.Canvas, s.Mode)
+	}
+	action, fields := s.target()
+	if action != "/examples/" {
+		t.Fatalf("Did not find expected action /examples/ instead found %s", action)
+	}
+	if fields.PageID != 0 || fields.UserID != 4 {
+		t.Fatalf("Did not find expected canvas fields instead found %+v", fields)
+	}
+}
+
+func TestSimulationPageTab(t *testing.T) {
+	t.Parallel()
+	s, err := parseSimulation(newRequest(t, "mode=page-tab&url=/examples/&page_liked=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	action, fields := s.target()
+	if action != "/" {
+		t.Fatalf("Did not find expected action / instead found %s", action)
+	}
+	if fields.PageID != simulatedPageID || !fields.PageLiked || fields.PageAdmin {
+		t.Fatalf("Did not find expected page fields instead found %+v", fields)
+	}
+	if fields.AppData != appdata.Encode(&url.URL{Path: "/examples/"}) {
+		t.Fatalf("Did not find expected app_data instead found %s", fields.AppData)
+	}
+	if s.Fields.PageID != 0 {
+		t.Fatal("Was not expecting target to modify the simulation.")
+	}
+}
+
+func TestSimulationPageTabRoutes(t *testing.T) {
+	t.Parallel()
+	s, err := parseSimulation(newRequest(t,
+		"mode=page-tab&url="+url.QueryEscape("/examples/?version=mid")))
+	if err != nil {
+		t.Fatal(err)
+	}
+	action, fields := s.target()
+	secret := []byte("the-secret")
+	signed, err := sr.Encode(fields.Payload(), secret, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := url.Values{"signed_request": []string{signed}}.Encode()
+	r, err := http.NewRequest("POST", action, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	var routed *url.URL
+	handler := &appdata.Handler{
+		Secret: secret,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			routed = r.URL
+		}),
+	}
+	handler.ServeHTTP(nil, r)
+	if routed == nil || routed.Path != "/examples/" || routed.Query().Get("version") != "mid" {
+		t.Fatalf("Did not find expected route /examples/?version=mid instead found %v", routed)
+	}
+}
+
+func TestSimulationInvalidURL(t *testing.T) {
+	t.Parallel()
+	for _, u := range []string{"http://evil.com/", "//evil.com/"} {
+		if _, err := parseSimulation(newRequest(t, "url="+u)); err != errInvalidURL {
+			t.Fatalf("Did not find expected errInvalidURL for %s instead found %v", u, err)
+		}
+	}
+}
+
+func TestTargetAppID(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		Action string
+		ID     uint64
+	}{
+		{"/examples/", 1},
+		{"/examples/?appid=2", 2},
+		{"/examples/?client_id=3&appid=2", 3},
+		{"/examples/?appid=x", 1},
+	}
+	for _, c := range cases {
+		if id := targetAppID(c.Action, 1); id != c.ID {
+			t.Fatalf("Did not find expected app %d for %s instead found %d",
+				c.ID, c.Action, id)
+		}
+	}
+}
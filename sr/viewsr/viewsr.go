@@ -0,0 +1,339 @@
+// Package viewsr provides the signed request inspector and generator.
+package viewsr
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daaku/go.errcode"
+	"github.com/daaku/go.fbapp"
+	"github.com/daaku/go.h"
+	"github.com/daaku/go.h.ui"
+	"github.com/daaku/go.static"
+
+	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/context/apps"
+	"github.com/daaku/rell/sr"
+	"github.com/daaku/rell/view"
+)
+
+const (
+	Path = "/signed-request/"
+
+	// Not "signed_request" since that would be parsed by the context.
+	paramRaw      = "raw"
+	paramGenerate = "generate"
+)
+
+// The result of decoding or generating a signed request.
+type result struct {
+	SignedRequest string      `json:"signedRequest"`
+	Decoded       *sr.Decoded `json:"decoded,omitempty"`
+	Error         string      `json:"error,omitempty"`
+}
+
+var errGenerateDisabled = errcode.New(
+	http.StatusForbidden, "Generating signed requests is disabled.")
+
+type Handler struct {
+	ContextParser *context.Parser
+	Static        *static.Handler
+	Apps          *apps.Registry
+
+	// Generating signed requests with the app secret allows anyone to forge
+	// them, so it should only be enabled for local development.
+	Generate bool
+}
+
+func (a *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := a.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	fields, err := parseFields(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+
+	var res *result
+	raw := strings.TrimSpace(r.FormValue(paramRaw))
+	generate := r.FormValue(paramGenerate) != ""
+	if generate && !a.Generate {
+		view.Error(w, r, a.Static, errGenerateDisabled)
+		return
+	}
+	var app fbapp.App
+	if generate || raw != "" {
+		if app, err = a.Apps.Lookup(c.AppID); err != nil {
+			view.Error(w, r, a.Static, err)
+			return
+		}
+	}
+	if generate {
+		raw, err = sr.Encode(fields.Payload(), app.SecretByte(), time.Now())
+		if err != nil {
+			view.Error(w, r, a.Static, err)
+			return
+		}
+	}
+	if raw != "" {
+		res = &result{SignedRequest: raw}
+		res.Decoded, err = sr.Decode(raw, app.SecretByte())
+		if err != nil {
+			res.Error = err.Error()
+		}
+	}
+
+	if view.WantsJSON(r) {
+		if res == nil {
+			view.Error(w, r, a.Static, errcode.New(
+				http.StatusBadRequest, "No signed request was provided."))
+			return
+		}
+		view.JSON(w, r, a.Static, res)
+		return
+	}
+	h.WriteResponse(w, r, &page{
+		Context:  c,
+		Static:   a.Static,
+		Fields:   fields,
+		Result:   res,
+		Generate: a.Generate,
+	})
+}
+
+// Parse the generator fields from the form.
+func parseFields(r *http.Request) (*sr.Fields, error) {
+	f := &sr.Fields{
+		OAuthToken: strings.TrimSpace(r.FormValue("oauth_token")),
+		AppData:    r.FormValue("app_data"),
+		Code:       strings.TrimSpace(r.FormValue("code")),
+		PageLiked:  r.FormValue("page_liked") != "",
+		PageAdmin:  r.FormValue("page_admin") != "",
+	}
+	var err error
+	if f.UserID, err = parseID(r, "user_id"); err != nil {
+		return nil, err
+	}
+	if f.PageID, err = parseID(r, "page_id"); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parseID(r *http.Request, name string) (uint64, error) {
+	value := strings.TrimSpace(r.FormValue(name))
+	if value == "" {
+		return 0, nil
+	}
+	id, err := strconv.ParseUint(value, 10, 64)
+	if err != nil {
+		return 0, errcode.New(http.StatusBadRequest, "Invalid %s: %s", name, value)
+	}
+	return id, nil
+}
+
+type page struct {
+	Context  *context.Context
+	Static   *static.Handler
+	Fields   *sr.Fields
+	Result   *result
+	Generate bool
+}
+
+func (p *page) HTML() (h.HTML, error) {
+	return &view.Page{
+		Context: p.Context,
+		Static:  p.Static,
+		Title:   "Signed Request",
+		Class:   "signed-request",
+		Body: &h.Div{
+			Class: "container",
+			Inner: &h.Frag{
+				&h.Div{
+					Class: "row",
+					Inner: &h.Div{
+						Class: "span12",
+						Inner: &h.Frag{
+							&h.H1{Inner: h.String("Signed Request")},
+							&resultView{Result: p.Result},
+						},
+					},
+				},
+				&h.Div{
+					Class: "row",
+					Inner: &h.Frag{
+						&h.Div{
+							Class: "span6",
+							Inner: &decodeForm{Context: p.Context, Result: p.Result},
+						},
+						&h.Div{
+							Class: "span6",
+							Inner: p.generateForm(),
+						},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+type resultView struct {
+	Result *result
+}
+
+func (v *resultView) HTML() (h.HTML, error) {
+	if v.Result == nil {
+		return nil, nil
+	}
+	status := &h.Div{
+		Class: "alert alert-success",
+		Inner: h.String("The signature is valid for the configured app secret."),
+	}
+	if v.Result.Error != "" {
+		status = &h.Div{
+			Class: "alert alert-error",
+			Inner: h.String(v.Result.Error),
+		}
+	}
+	frag := &h.Frag{
+		status,
+		&h.Textarea{
+			Class: "input-block-level signed-request-raw",
+			Inner: h.String(v.Result.SignedRequest),
+		},
+	}
+	if d := v.Result.Decoded; d != nil {
+		payload, err := json.MarshalIndent(d.Payload, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		frag.Append(&h.Textarea{
+			Class: "input-block-level signed-request-payload",
+			Inner: h.String(string(payload)),
+		})
+	}
+	return frag, nil
+}
+
+type decodeForm struct {
+	Context *context.Context
+	Result  *result
+}
+
+func (f *decodeForm) HTML() (h.HTML, error) {
+	var raw string
+	if f.Result != nil {
+		raw = f.Result.SignedRequest
+	}
+	return &h.Form{
+		Action: Path,
+		Method: h.Post,
+		Inner: &h.Frag{
+			&h.H2{Inner: h.String("Decode")},
+			h.HiddenInputs(f.Context.Values()),
+			&h.Textarea{
+				Class: "input-block-level signed-request-raw",
+				Name:  paramRaw,
+				Inner: h.String(raw),
+			},
+			&h.Button{
+				Type:  "submit",
+				Class: "btn btn-primary",
+				Inner: h.String("Decode & Verify"),
+			},
+		},
+	}, nil
+}
+
+func (p *page) generateForm() h.HTML {
+	if !p.Generate {
+		return nil
+	}
+	return &generateForm{Context: p.Context, Fields: p.Fields}
+}
+
+type generateForm struct {
+	Context *context.Context
+	Fields  *sr.Fields
+}
+
+func (f *generateForm) HTML() (h.HTML, error) {
+	return &h.Form{
+		Action: Path,
+		Method: h.Post,
+		Class:  "form-horizontal",
+		Inner: &h.Frag{
+			&h.H2{Inner: h.String("Generate")},
+			h.HiddenInputs(f.Context.Values()),
+			&ui.TextInput{
+				Label:      h.String("User ID"),
+				Name:       "user_id",
+				Value:      formatID(f.Fields.UserID),
+				InputClass: "input-medium",
+				Tooltip:    "Leave empty to simulate a user who has not authorized the app.",
+			},
+			&ui.TextInput{
+				Label:      h.String("OAuth Token"),
+				Name:       "oauth_token",
+				Value:      f.Fields.OAuthToken,
+				InputClass: "input-medium",
+			},
+			&ui.TextInput{
+				Label:      h.String("Code"),
+				Name:       "code",
+				Value:      f.Fields.Code,
+				InputClass: "input-medium",
+			},
+			&ui.TextInput{
+				Label:      h.String("Page ID"),
+				Name:       "page_id",
+				Value:      formatID(f.Fields.PageID),
+				InputClass: "input-medium",
+				Tooltip:    "Set to simulate a Page Tab, leave empty for Canvas.",
+			},
+			&ui.TextInput{
+				Label:      h.String("App Data"),
+				Name:       "app_data",
+				Value:      f.Fields.AppData,
+				InputClass: "input-medium",
+			},
+			&ui.ToggleGroup{
+				Inner: &h.Frag{
+					&ui.ToggleItem{
+						Name:        "page_liked",
+						Checked:     f.Fields.PageLiked,
+						Description: h.String("The user likes the page."),
+					},
+					&ui.ToggleItem{
+						Name:        "page_admin",
+						Checked:     f.Fields.PageAdmin,
+						Description: h.String("The user is a page admin."),
+					},
+				},
+			},
+			&h.Div{
+				Class: "form-actions",
+				Inner: &h.Button{
+					Type:  "submit",
+					Class: "btn btn-primary",
+					Name:  paramGenerate,
+					Value: "1",
+					Inner: h.String("Generate"),
+				},
+			},
+		},
+	}, nil
+}
+
+func formatID(id uint64) string {
+	if id == 0 {
+		return ""
+	}
+	return strconv.FormatUint(id, 10)
+}
@@ -0,0 +1,94 @@
+package viewsr
+
+import (
+	"bytes"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/daaku/go.fbapp"
+
+	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/context/appns"
+	"github.com/daaku/rell/context/apps"
+	"github.com/daaku/rell/sr"
+)
+
+var (
+	app      = fbapp.New(184484190795, "the-secret", "fbrell")
+	issuedAt = time.Unix(1370000000, 0)
+
+	// Known to the namespace fetcher but without a configured secret.
+	unconfigured = fbapp.New(42, "", "other")
+)
+
+func newHandler() *Handler {
+	return &Handler{
+		ContextParser: &context.Parser{
+			App:          app,
+			AppNSFetcher: &appns.Fetcher{Apps: []fbapp.App{app, unconfigured}},
+		},
+		Apps: &apps.Registry{Default: app},
+	}
+}
+
+// Post an empty multipart body, the parameters are in the URL.
+func post(handler http.HandlerFunc, u string) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	mw.Close()
+	r, _ := http.NewRequest("POST", u, &body)
+	r.Header.Set("Content-Type", mw.FormDataContentType())
+	w := httptest.NewRecorder()
+	handler(w, r)
+	return w
+}
+
+func TestDecodeMismatchHidesSignature(t *testing.T) {
+	t.Parallel()
+	raw, err := sr.Encode(map[string]interface{}{"user_id": "4"}, []byte("other"), issuedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := post(newHandler().ServeHTTP, Path+"?format=json&raw="+url.QueryEscape(raw))
+	body := w.Body.String()
+	if !strings.Contains(body, sr.ErrSignature.Error()) {
+		t.Fatalf("Did not find expected mismatch error in %s", body)
+	}
+	expected, _ := sr.Encode(map[string]interface{}{"user_id": "4"}, app.SecretByte(), issuedAt)
+	if sig := expected[:strings.Index(expected, ".")]; strings.Contains(body, sig) {
+		t.Fatalf("Was not expecting the correct signature in %s", body)
+	}
+	if strings.Contains(body, "expected") {
+		t.Fatalf("Was not expecting an expected signature in %s", body)
+	}
+}
+
+func TestGenerateDisabledByDefault(t *testing.T) {
+	t.Parallel()
+	handler := newHandler()
+	w := post(handler.ServeHTTP, Path+"?format=json&generate=1&user_id=4")
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Did not find expected forbidden generate, found %d", w.Code)
+	}
+	w = post(handler.Simulator, SimulatorPath)
+	if w.Code != http.StatusForbidden {
+		t.Fatalf("Did not find expected forbidden simulator, found %d", w.Code)
+	}
+}
+
+func TestDecodeUnconfiguredApp(t *testing.T) {
+	t.Parallel()
+	raw, err := sr.Encode(map[string]interface{}{"user_id": "4"}, app.SecretByte(), issuedAt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w := post(newHandler().ServeHTTP, Path+"?format=json&appid=42&raw="+url.QueryEscape(raw))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("Did not find expected error for an unconfigured app, found %d", w.Code)
+	}
+}
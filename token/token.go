@@ -0,0 +1,279 @@
+// Package token implements an access token debugger backed by the Graph
+// debug_token endpoint.
+package token
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/daaku/go.errcode"
+	"github.com/daaku/go.fburl"
+	"github.com/daaku/go.h"
+	"github.com/daaku/go.static"
+
+	"github.com/daaku/rell/context"
+	"github.com/daaku/rell/context/apps"
+	"github.com/daaku/rell/oauth"
+	"github.com/daaku/rell/view"
+)
+
+const (
+	Path      = "/token/"
+	paramName = "access_token"
+)
+
+var errDebugFail = errcode.New(
+	http.StatusBadGateway, "Failed to debug the access token: no data returned.")
+
+// Information about an access token.
+type Info struct {
+	AppID       string   `json:"appID"`
+	Application string   `json:"application,omitempty"`
+	UserID      string   `json:"userID,omitempty"`
+	Scopes      []string `json:"scopes"`
+	IssuedAt    int64    `json:"issuedAt,omitempty"`  // Unix time.
+	ExpiresAt   int64    `json:"expiresAt,omitempty"` // Zero if it never expires.
+	Valid       bool     `json:"valid"`
+	Error       string   `json:"error,omitempty"`
+}
+
+type Handler struct {
+	ContextParser *context.Parser
+	HttpTransport http.RoundTripper
+	Static        *static.Handler
+	Apps          *apps.Registry
+}
+
+func (a *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	c, err := a.ContextParser.FromRequest(r)
+	if err != nil {
+		view.Error(w, r, a.Static, err)
+		return
+	}
+	accessToken := findToken(c, r, a.Apps.Default.SecretByte())
+	var info *Info
+	if accessToken != "" {
+		values := url.Values{}
+		values.Set("input_token", accessToken)
+		app, err := a.Apps.Lookup(c.AppID)
+		if err != nil {
+			view.Error(w, r, a.Static, err)
+			return
+		}
+		values.Set("access_token", fmt.Sprintf("%d|%s", app.ID(), app.Secret()))
+		debugURL := &fburl.URL{
+			Scheme:    "https",
+			SubDomain: fburl.DGraph,
+			Env:       c.Env,
+			Path:      "/debug_token",
+			Values:    values,
+		}
+		info, err = debug(a.HttpTransport, debugURL.String())
+		if err != nil {
+			view.Error(w, r, a.Static, err)
+			return
+		}
+	}
+	if view.WantsJSON(r) {
+		if info == nil {
+			view.Error(w, r, a.Static, errcode.New(
+				http.StatusBadRequest, "No access token was provided."))
+			return
+		}
+		view.JSON(w, r, a.Static, info)
+		return
+	}
+	h.WriteResponse(w, r, &page{
+		Context: c,
+		Static:  a.Static,
+		Token:   accessToken,
+		Info:    info,
+	})
+}
+
+// Find the token to debug, preferring the form, then the signed request from
+// the fbsr_ cookie or canvas and finally the server side session.
+func findToken(c *context.Context, r *http.Request, secret []byte) string {
+	if t := strings.TrimSpace(r.FormValue(paramName)); t != "" {
+		return t
+	}
+	if c.SignedRequest != nil && c.SignedRequest.OAuthToken != "" {
+		return c.SignedRequest.OAuthToken
+	}
+	return oauth.SessionToken(secret, r)
+}
+
+// The debug_token response from the Graph API.
+type debugResponse struct {
+	Data *struct {
+		AppID       string   `json:"app_id"`
+		Application string   `json:"application"`
+		UserID      string   `json:"user_id"`
+		Scopes      []string `json:"scopes"`
+		IssuedAt    int64    `json:"issued_at"`
+		ExpiresAt   int64    `json:"expires_at"`
+		IsValid     bool     `json:"is_valid"`
+		Error       *struct {
+			Message string `json:"message"`
+		} `json:"error"`
+	} `json:"data"`
+	Error *struct {
+		Message string `json:"message"`
+		Type    string `json:"type"`
+		Code    int    `json:"code"`
+	} `json:"error"`
+}
+
+// Fetch the debug information from the given debug_token URL.
+func debug(transport http.RoundTripper, debugURL string) (*Info, error) {
+	req, err := http.NewRequest("GET", debugURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := transport.RoundTrip(req)
+	if err != nil {
+		return nil, debugError(err)
+	}
+	defer res.Body.Close()
+	body, err := ioutil.ReadAll(io.LimitReader(res.Body, oauth.MaxResponseSize))
+	if err != nil {
+		return nil, debugError(err)
+	}
+	var v debugResponse
+	if err := json.Unmarshal(body, &v); err != nil {
+		return nil, debugError(err)
+	}
+	if v.Error != nil {
+		return nil, errcode.New(
+			http.StatusBadGateway, "debug_token failed: %s (%s %d)",
+			v.Error.Message, v.Error.Type, v.Error.Code)
+	}
+	if res.StatusCode != http.StatusOK || v.Data == nil {
+		return nil, errDebugFail
+	}
+
+	info := &Info{
+		AppID:       v.Data.AppID,
+		Application: v.Data.Application,
+		UserID:      v.Data.UserID,
+		Scopes:      v.Data.Scopes,
+		IssuedAt:    v.Data.IssuedAt,
+		ExpiresAt:   v.Data.ExpiresAt,
+		Valid:       v.Data.IsValid,
+	}
+	if info.Scopes == nil {
+		info.Scopes = []string{}
+	}
+	if v.Data.Error != nil {
+		info.Error = v.Data.Error.Message
+	}
+	return info, nil
+}
+
+// Wrap the underlying cause of a failed debug.
+func debugError(err error) error {
+	return errcode.New(
+		http.StatusBadGateway, "Failed to debug the access token: %s", err)
+}
+
+type page struct {
+	Context *context.Context
+	Static  *static.Handler
+	Token   string
+	Info    *Info
+}
+
+func (p *page) HTML() (h.HTML, error) {
+	return &view.Page{
+		Context: p.Context,
+		Static:  p.Static,
+		Title:   "Access Token Debugger",
+		Class:   "token",
+		Body: &h.Div{
+			Class: "container",
+			Inner: &h.Div{
+				Class: "row",
+				Inner: &h.Div{
+					Class: "span12",
+					Inner: &h.Frag{
+						&h.H1{Inner: h.String("Access Token Debugger")},
+						&h.Form{
+							Action: Path,
+							Method: h.Post,
+							Inner: &h.Frag{
+								h.HiddenInputs(p.Context.Values()),
+								&h.Input{
+									Type:  "text",
+									Name:  paramName,
+									Value: p.Token,
+									Class: "input-xxlarge",
+								},
+								h.String(" "),
+								&h.Button{
+									Type:  "submit",
+									Class: "btn",
+									Inner: h.String("Debug"),
+								},
+							},
+						},
+						&infoTable{Info: p.Info},
+					},
+				},
+			},
+		},
+	}, nil
+}
+
+type infoTable struct {
+	Info *Info
+}
+
+func (t *infoTable) HTML() (h.HTML, error) {
+	if t.Info == nil {
+		return nil, nil
+	}
+	i := t.Info
+	expires := "Never"
+	if i.ExpiresAt != 0 {
+		expires = time.Unix(i.ExpiresAt, 0).UTC().Format(time.RFC1123)
+	}
+	issued := ""
+	if i.IssuedAt != 0 {
+		issued = time.Unix(i.IssuedAt, 0).UTC().Format(time.RFC1123)
+	}
+	app := i.AppID
+	if i.Application != "" {
+		app = i.Application + " (" + i.AppID + ")"
+	}
+	rows := &h.Frag{
+		row("Valid", strconv.FormatBool(i.Valid)),
+		row("App", app),
+		row("User", i.UserID),
+		row("Scopes", strings.Join(i.Scopes, ", ")),
+		row("Issued", issued),
+		row("Expires", expires),
+	}
+	if i.Error != "" {
+		rows.Append(row("Error", i.Error))
+	}
+	return &h.Table{
+		Class: "table table-bordered table-striped",
+		Inner: &h.Tbody{Inner: rows},
+	}, nil
+}
+
+func row(name, value string) h.HTML {
+	return &h.Tr{
+		Inner: &h.Frag{
+			&h.Th{Inner: h.String(name)},
+			&h.Td{Inner: h.String(value)},
+		},
+	}
+}
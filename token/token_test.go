@@ -0,0 +1,105 @@
+package token
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/daaku/go.errcode"
+
+	"github.com/daaku/rell/graphtest"
+)
+
+func newFakeGraph(status int, body string) *graphtest.Transport {
+	return graphtest.New(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/debug_token" {
+				http.NotFound(w, r)
+				return
+			}
+			w.WriteHeader(status)
+			fmt.Fprint(w, body)
+		}))
+}
+
+const debugURL = "https://graph.facebook.com/debug_token?input_token=abc"
+
+func TestDebugValid(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(200, `{"data":{"app_id":"123","application":"Rell","user_id":"4","scopes":["email","user_likes"],"issued_at":100,"expires_at":200,"is_valid":true}}`)
+	defer g.Close()
+	info, err := debug(g, debugURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.AppID != "123" || info.UserID != "4" || !info.Valid {
+		t.Fatalf("Did not find expected info instead found %+v", info)
+	}
+	if strings.Join(info.Scopes, ",") != "email,user_likes" {
+		t.Fatalf("Did not find expected scopes instead found %v", info.Scopes)
+	}
+	if info.ExpiresAt != 200 || info.IssuedAt != 100 {
+		t.Fatalf("Did not find expected times instead found %+v", info)
+	}
+}
+
+func TestDebugInvalid(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(200, `{"data":{"app_id":"123","is_valid":false,"error":{"message":"Session has expired."}}}`)
+	defer g.Close()
+	info, err := debug(g, debugURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Valid || info.Error != "Session has expired." {
+		t.Fatalf("Did not find expected invalid info instead found %+v", info)
+	}
+	if info.Scopes == nil {
+		t.Fatal("Was expecting empty scopes rather than nil.")
+	}
+}
+
+func TestDebugErrorPayload(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(400, `{"error":{"message":"Invalid OAuth access token.","type":"OAuthException","code":190}}`)
+	defer g.Close()
+	_, err := debug(g, debugURL)
+	if err == nil {
+		t.Fatal("Was expecting an error.")
+	}
+	if !strings.Contains(err.Error(), "Invalid OAuth access token.") {
+		t.Fatalf("Did not find expected message instead found %s", err)
+	}
+}
+
+func TestDebugGarbage(t *testing.T) {
+	t.Parallel()
+	g := newFakeGraph(200, `not json`)
+	defer g.Close()
+	_, err := debug(g, debugURL)
+	if err == nil || !strings.Contains(err.Error(), "invalid character") {
+		t.Fatalf("Did not find expected underlying error instead found %v", err)
+	}
+	if e, ok := err.(errcode.E); !ok || e.Code() != http.StatusBadGateway {
+		t.Fatalf("Did not find expected code %d for %v", http.StatusBadGateway, err)
+	}
+}
+
+type failTransport struct{}
+
+func (failTransport) RoundTrip(*http.Request) (*http.Response, error) {
+	return nil, errors.New("connection refused")
+}
+
+func TestDebugTransportError(t *testing.T) {
+	t.Parallel()
+	_, err := debug(failTransport{}, debugURL)
+	if err == nil || !strings.Contains(err.Error(), "connection refused") {
+		t.Fatalf("Did not find expected underlying error instead found %v", err)
+	}
+	if e, ok := err.(errcode.E); !ok || e.Code() != http.StatusBadGateway {
+		t.Fatalf("Did not find expected code %d for %v", http.StatusBadGateway, err)
+	}
+}
@@ -1,14 +1,24 @@
 package view
 
 import (
+	"encoding/json"
 	"io"
 	"log"
 	"net/http"
+	"strconv"
 	"strings"
 
 	"github.com/daaku/go.errcode"
 	"github.com/daaku/go.h"
 	"github.com/daaku/go.static"
+
+	"github.com/daaku/rell/requestlog"
+)
+
+const (
+	mimeHTML  = "text/html"
+	mimeJSON  = "application/json"
+	mimePlain = "text/plain"
 )
 
 // HTTP Coded Error.
@@ -23,25 +33,59 @@ type errorCodeHandler struct {
 	err    ErrorCode
 }
 
-// Serve an appropriate response for this error. Currently this means
-// HTML or Plain Text.
-// TODO(naitik): Extend for JSON.
+// The JSON representation of an error.
+type jsonError struct {
+	Code      int    `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"requestID,omitempty"`
+}
+
+// Serve an appropriate response for this error. This means HTML, JSON
+// or Plain Text based on the Accept header, with a "format=json" query
+// parameter forcing JSON.
 func (err errorCodeHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	code := err.err.Code()
 	if code == 0 {
 		code = http.StatusInternalServerError
 	}
-	if usePlainText(r) {
+	requestID := requestlog.ID(r)
+	switch errorFormat(r) {
+	case mimeJSON:
+		out, _ := json.Marshal(map[string]jsonError{
+			"error": jsonError{
+				Code:      code,
+				Message:   err.err.Error(),
+				RequestID: requestID,
+			},
+		})
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(code)
+		w.Write(out)
+		w.Write([]byte("\n"))
+	case mimePlain:
 		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
 		w.WriteHeader(code)
 		io.Copy(w, strings.NewReader(err.err.Error()))
 		w.Write([]byte("\n"))
-	} else {
+		if requestID != "" {
+			io.WriteString(w, "Request ID: "+requestID+"\n")
+		}
+	default:
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 		w.WriteHeader(code)
+		var requestIDHTML h.HTML
+		if requestID != "" {
+			requestIDHTML = &h.Div{
+				Class: "muted",
+				Inner: h.String("Request ID: " + requestID),
+			}
+		}
 		page := &Page{
 			Static: err.Static,
-			Body:   h.String(err.err.Error()),
+			Body: &h.Frag{
+				h.String(err.err.Error()),
+				requestIDHTML,
+			},
 		}
 		h.WriteResponse(w, r, page)
 	}
@@ -56,7 +100,8 @@ func Error(w http.ResponseWriter, r *http.Request, s *static.Handler, err error)
 		errCode, ok := err.(ErrorCode)
 		if !ok {
 			errCode = errcode.Add(500, err)
-			log.Printf("Error %d: %s %s %v", errCode.Code(), r.URL, err, err)
+			log.Printf("Error %d: %s %s %v (request %s)",
+				errCode.Code(), r.URL, err, err, requestlog.ID(r))
 		}
 		handler = errorCodeHandler{
 			Static: s,
@@ -66,6 +111,68 @@ func Error(w http.ResponseWriter, r *http.Request, s *static.Handler, err error)
 	handler.ServeHTTP(w, r)
 }
 
+// Pick the format for an error response.
+func errorFormat(r *http.Request) string {
+	if r.URL.Query().Get("format") == "json" {
+		return mimeJSON
+	}
+	accept := r.Header.Get("Accept")
+	if accept == "" || accept == "*/*" {
+		if usePlainText(r) {
+			return mimePlain
+		}
+		return mimeHTML
+	}
+	return negotiate(accept, mimeHTML, mimeJSON, mimePlain)
+}
+
 func usePlainText(r *http.Request) bool {
 	return strings.Contains(r.UserAgent(), "curl")
 }
+
+// Pick the offer best matching the Accept header. Ties go to the offer
+// listed first, and the first offer is returned if nothing matches.
+func negotiate(accept string, offers ...string) string {
+	best, bestQ, bestSpecificity := offers[0], -1.0, -1
+	for _, offer := range offers {
+		for _, part := range strings.Split(accept, ",") {
+			fields := strings.Split(part, ";")
+			mediaRange := strings.ToLower(strings.TrimSpace(fields[0]))
+			specificity := matchMediaRange(mediaRange, offer)
+			if specificity < 0 {
+				continue
+			}
+			q := 1.0
+			for _, param := range fields[1:] {
+				param = strings.TrimSpace(param)
+				if strings.HasPrefix(param, "q=") {
+					if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+						q = v
+					}
+				}
+			}
+			if q > bestQ || q == bestQ && specificity > bestSpecificity {
+				best, bestQ, bestSpecificity = offer, q, specificity
+			}
+		}
+	}
+	if bestQ <= 0 {
+		return offers[0]
+	}
+	return best
+}
+
+// Returns -1 if the media range does not match the offer, otherwise a
+// larger number for more specific matches.
+func matchMediaRange(mediaRange, offer string) int {
+	switch {
+	case mediaRange == offer:
+		return 2
+	case mediaRange == "*/*":
+		return 0
+	case strings.HasSuffix(mediaRange, "/*") &&
+		strings.HasPrefix(offer, mediaRange[:len(mediaRange)-1]):
+		return 1
+	}
+	return -1
+}
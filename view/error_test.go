@@ -0,0 +1,56 @@
+package view
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestNegotiate(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		accept   string
+		expected string
+	}{
+		{"application/json", mimeJSON},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", mimeHTML},
+		{"application/json, text/javascript, */*; q=0.01", mimeJSON},
+		{"text/plain", mimePlain},
+		{"text/*;q=0.5, application/json;q=0.4", mimeHTML},
+		{"image/png", mimeHTML},
+		{"application/json;q=0", mimeHTML},
+	}
+	for _, c := range cases {
+		actual := negotiate(c.accept, mimeHTML, mimeJSON, mimePlain)
+		if actual != c.expected {
+			t.Fatalf("Did not find expected %s for %q instead found %s",
+				c.expected, c.accept, actual)
+		}
+	}
+}
+
+func TestWantsJSON(t *testing.T) {
+	t.Parallel()
+	cases := []struct {
+		url      string
+		accept   string
+		expected bool
+	}{
+		{"/token/?format=json", "", true},
+		{"/token/", "application/json", true},
+		{"/token/", "text/html,application/xhtml+xml,*/*;q=0.8", false},
+		{"/token/", "", false},
+		{"/token/", "*/*", false},
+	}
+	for _, c := range cases {
+		r, err := http.NewRequest("GET", c.url, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if c.accept != "" {
+			r.Header.Set("Accept", c.accept)
+		}
+		if actual := WantsJSON(r); actual != c.expected {
+			t.Fatalf("Did not find expected %v for %s %q", c.expected, c.url, c.accept)
+		}
+	}
+}
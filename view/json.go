@@ -0,0 +1,30 @@
+package view
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/daaku/go.static"
+)
+
+// Send a JSON response for the given value.
+func JSON(w http.ResponseWriter, r *http.Request, s *static.Handler, v interface{}) {
+	out, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		Error(w, r, s, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Write(out)
+	w.Write([]byte("\n"))
+}
+
+// Check if the request prefers a JSON response, either explicitly via
+// ?format=json or through the Accept header.
+func WantsJSON(r *http.Request) bool {
+	if r.URL.Query().Get("format") == "json" {
+		return true
+	}
+	accept := r.Header.Get("Accept")
+	return accept != "" && negotiate(accept, mimeHTML, mimeJSON) == mimeJSON
+}
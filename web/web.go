@@ -5,10 +5,10 @@ import (
 	"net/http"
 	"net/http/pprof"
 	"path/filepath"
+	"strconv"
 	"sync"
 
 	"github.com/daaku/go.browserify"
-	"github.com/daaku/go.fbapp"
 	"github.com/daaku/go.httpdev"
 	"github.com/daaku/go.httpgzip"
 	"github.com/daaku/go.httpstats"
@@ -17,10 +17,14 @@ import (
 	"github.com/daaku/go.stats"
 	"github.com/daaku/go.viewvar"
 
+	"github.com/daaku/rell/context/apps"
 	"github.com/daaku/rell/context/viewcontext"
 	"github.com/daaku/rell/examples/viewexamples"
 	"github.com/daaku/rell/oauth"
 	"github.com/daaku/rell/og/viewog"
+	"github.com/daaku/rell/requestlog"
+	"github.com/daaku/rell/sr/viewsr"
+	"github.com/daaku/rell/token"
 )
 
 // The rell web application.
@@ -29,9 +33,12 @@ type App struct {
 	ExamplesHandler *viewexamples.Handler
 	OgHandler       *viewog.Handler
 	OauthHandler    *oauth.Handler
+	TokenHandler    *token.Handler
+	SrHandler       *viewsr.Handler
 	Stats           stats.Backend
 	Static          *static.Handler
-	App             fbapp.App
+	Apps            *apps.Registry
+	RequestLog      *requestlog.Handler // Wraps the main handler.
 
 	adminHandler     http.Handler
 	adminHandlerOnce sync.Once
@@ -48,6 +55,11 @@ func (a *App) AdminHandler(w http.ResponseWriter, r *http.Request) {
 		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
 		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
 		mux.HandleFunc("/vars/", viewvar.Json)
+		mux.HandleFunc("/examples/reload", a.ExamplesHandler.Reload)
+		mux.HandleFunc("/examples/store", a.ExamplesHandler.StoreStats)
+		mux.HandleFunc("/examples/prune", a.ExamplesHandler.Prune)
+		mux.HandleFunc("/examples/export", a.ExamplesHandler.Export)
+		mux.HandleFunc("/examples/import", a.ExamplesHandler.Import)
 		a.adminHandler = mux
 	})
 	a.adminHandler.ServeHTTP(w, r)
@@ -71,7 +83,13 @@ func (a *App) MainHandler(w http.ResponseWriter, r *http.Request) {
 		mux.HandleFunc(browserify.Path, browserify.Handle)
 		mux.HandleFunc("/not_a_real_webpage", http.NotFound)
 		mux.Handle("/info/", a.ContextHandler)
+		mux.HandleFunc(viewcontext.PrefsSavePath, a.ContextHandler.SavePrefs)
+		mux.HandleFunc(viewcontext.PrefsResetPath, a.ContextHandler.ResetPrefs)
 		mux.HandleFunc("/examples/", a.ExamplesHandler.List)
+		mux.HandleFunc("/examples/search", a.ExamplesHandler.Search)
+		mux.HandleFunc("/examples/compat", a.ExamplesHandler.Compat)
+		mux.HandleFunc("/api/examples", a.ExamplesHandler.API)
+		mux.HandleFunc("/api/examples/", a.ExamplesHandler.API)
 		mux.HandleFunc("/saved/", a.ExamplesHandler.Saved)
 		mux.HandleFunc("/raw/", a.ExamplesHandler.Raw)
 		mux.HandleFunc("/simple/", a.ExamplesHandler.Simple)
@@ -80,21 +98,36 @@ func (a *App) MainHandler(w http.ResponseWriter, r *http.Request) {
 		mux.HandleFunc("/og/", a.OgHandler.Values)
 		mux.HandleFunc("/rog/", a.OgHandler.Base64)
 		mux.HandleFunc("/rog-redirect/", a.OgHandler.Redirect)
+		mux.HandleFunc(viewog.BuilderPath, a.OgHandler.Builder)
+		mux.HandleFunc(viewog.StoredPath, a.OgHandler.Stored)
 		mux.Handle(oauth.Path, a.OauthHandler)
+		mux.Handle(token.Path, a.TokenHandler)
+		mux.Handle(viewsr.Path, a.SrHandler)
+		mux.HandleFunc(viewsr.SimulatorPath, a.SrHandler.Simulator)
 		mux.HandleFunc("/sleep/", httpdev.Sleep)
 
 		var handler http.Handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, pattern := mux.Handler(r)
+			a.RequestLog.Set(r, "handler", pattern)
+			mux.ServeHTTP(w, r)
+		})
 		handler = &httpstats.Handler{
 			Name:    "web",
-			Handler: mux,
+			Handler: handler,
 			Stats:   a.Stats,
 		}
-		handler = &appdata.Handler{
+		handler = &appDataHandler{
 			Handler: handler,
-			Secret:  a.App.SecretByte(),
+			Apps:    a.Apps,
 		}
+		// Logged outside of gzip, so the logged bytes are the compressed size.
 		handler = httpgzip.NewHandler(handler)
-		a.mainHandler = handler
+		if a.RequestLog == nil {
+			a.RequestLog = &requestlog.Handler{}
+		}
+		a.RequestLog.Handler = handler
+		a.mainHandler = a.RequestLog
 	})
 	a.mainHandler.ServeHTTP(w, r)
 }
@@ -106,3 +139,29 @@ func (a *App) staticFile(mux *http.ServeMux, name string) {
 		http.ServeFile(w, r, abs)
 	})
 }
+
+// Routes Page Tab requests using the app_data in the signed request. Like the
+// context, the app is the one named by the client_id or appid parameters and
+// the signed request is verified with its secret.
+type appDataHandler struct {
+	Handler http.Handler
+	Apps    *apps.Registry
+}
+
+func (a *appDataHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	raw := r.FormValue("client_id")
+	if raw == "" {
+		raw = r.FormValue("appid")
+	}
+	id, err := strconv.ParseUint(raw, 10, 64)
+	if err != nil {
+		id = a.Apps.Default.ID()
+	}
+	app, err := a.Apps.Lookup(id)
+	if err != nil {
+		a.Handler.ServeHTTP(w, r)
+		return
+	}
+	handler := &appdata.Handler{Handler: a.Handler, Secret: app.SecretByte()}
+	handler.ServeHTTP(w, r)
+}